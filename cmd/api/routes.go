@@ -24,6 +24,8 @@ func (app *application) routes() http.Handler {
 	}
 
 	r.POST("/payments/callback", h.CheckPaymentStatus)
+	r.POST("/events/invalidate", h.InvalidateEventsCache)
+	r.POST("/events/callback", h.HandleEventUpdate)
 
 	return r
 }
@@ -39,14 +39,24 @@ func main() {
 	// Initialize the worker server
 	srv := asynq.NewServer(
 		queueOpts,
-		asynq.Config{Concurrency: 10},
+		asynq.Config{
+			Concurrency:    10,
+			RetryDelayFunc: tasks.RetryDelay,
+			ErrorHandler:   asynq.ErrorHandlerFunc(tasks.HandleTaskError),
+		},
 	)
 
+	// Follow-up tasks are queued by the task handlers
+	tasksQueue := asynq.NewClient(queueOpts)
+	defer tasksQueue.Close()
+
 	// Define tasks handlers
-	h := tasks.NewHandler(env, cache)
+	h := tasks.NewHandler(env, cache, tasksQueue)
 
 	mux := asynq.NewServeMux()
 	mux.HandleFunc("payment_queue", h.HandlePaymentWebhookTask)
+	mux.HandleFunc("event_update_queue", h.HandleEventUpdateTask)
+	mux.HandleFunc("checkout_renewal_queue", h.HandleCheckoutRenewalTask)
 
 	// Start the worker server
 	go func() {
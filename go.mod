@@ -9,6 +9,7 @@ require (
 	github.com/redis/go-redis/v9 v9.17.3
 	github.com/rs/zerolog v1.34.0
 	github.com/ulule/limiter/v3 v3.11.2
+	golang.org/x/sync v0.19.0
 	google.golang.org/genai v1.43.0
 )
 
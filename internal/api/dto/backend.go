@@ -1,13 +1,68 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentPending       PaymentStatus = "pending"        // Payment made, awaiting confirmation from the bank
+	PaymentSuccess       PaymentStatus = "success"        // Payment confirmed and tickets issued
+	PaymentFailed        PaymentStatus = "failed"         // Payment declined or not completed
+	PaymentExpired       PaymentStatus = "expired"        // Checkout link timed out before payment
+	PaymentAbandoned     PaymentStatus = "abandoned"      // Checkout opened but left without paying
+	PaymentRefund        PaymentStatus = "refund"         // Full amount refunded and tickets cancelled
+	PaymentPartialRefund PaymentStatus = "partial_refund" // Part of the amount refunded
+	PaymentChargeback    PaymentStatus = "chargeback"     // Payment disputed with the bank and reversed
+)
+
+func (s PaymentStatus) Valid() bool {
+	switch s {
+	case PaymentPending, PaymentSuccess, PaymentFailed, PaymentExpired, PaymentAbandoned,
+		PaymentRefund, PaymentPartialRefund, PaymentChargeback:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConversationState returns the state the conversation moves to when the user is told of the payment status
+func (s PaymentStatus) ConversationState() ConversationState {
+	switch s {
+	case PaymentPending:
+		return StateAwaitingPayment
+	case PaymentFailed, PaymentExpired, PaymentAbandoned:
+		// The user can retry the purchase with the same ticket selection
+		return StateTicketTierSelected
+	default:
+		return StateCompleted
+	}
+}
 
 type PaymentWebhookPayload struct {
-	Reference string  `json:"reference"`
-	Status    string  `json:"status"` // "success" | "failed" | "refund"
-	PhoneID   string  `json:"phoneId"`
-	Email     string  `json:"email"`
-	Reason    *string `json:"reason,omitempty"`
+	Reference      string        `json:"reference"`
+	Status         PaymentStatus `json:"status"`
+	PhoneID        string        `json:"phoneId"`
+	Email          string        `json:"email"`
+	Reason         *string       `json:"reason,omitempty"`
+	RefundedAmount *Money        `json:"refundedAmount,omitempty"` // Set for partial refunds
+}
+
+// EventWebhookPayload is sent by the backend service when an organizer changes an event
+type EventWebhookPayload struct {
+	Type          string   `json:"type"` // "updated" | "cancelled" | "sold_out"
+	EventID       int      `json:"eventId"`
+	Title         string   `json:"title"`
+	Changes       *string  `json:"changes,omitempty"`       // Summary of the changes made by the organizer
+	TicketHolders []string `json:"ticketHolders,omitempty"` // Whatsapp phone IDs of users holding tickets for the event
+}
+
+// CacheInvalidationPayload lists the events that changed in the backend service. An empty list
+// means any event may have changed.
+type CacheInvalidationPayload struct {
+	EventIDs []int `json:"eventIds"`
 }
 
 type Event struct {
@@ -21,18 +76,147 @@ type Event struct {
 	Venue          string    `json:"venue"`
 	Address        string    `json:"address"`
 	Poster         string    `json:"poster"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	Distance       *float64  `json:"distance,omitempty"` // Kilometres from the user, only set in nearby searches
 }
 
 type TicketTier struct {
 	Name                    string     `json:"name"`
 	ID                      int32      `json:"id"`
-	Price                   float64    `json:"price"`
+	Price                   Money      `json:"price"`
+	Currency                string     `json:"currency,omitempty"`
 	Discount                bool       `json:"discount"`
-	DiscountPrice           *float64   `json:"discountPrice,omitempty"`
+	DiscountPrice           *Money     `json:"discountPrice,omitempty"`
 	DiscountExpiration      *time.Time `json:"discountExpiration,omitempty"`
 	NumberOfDiscountTickets *int       `json:"numberOfDiscountTickets,omitempty"`
 	DiscountStatus          *string    `json:"discountStatus,omitempty"` // "ACTIVE" | "ENDED"
 	Benefits                *string    `json:"benefits,omitempty"`
 	TotalNumberOfTickets    int        `json:"totalNumberOfTickets"`
+	RemainingTickets        *int       `json:"remainingTickets,omitempty"`
 	SoldOut                 bool       `json:"soldOut"`
 }
+
+// FunctionError is returned to the model in place of a function result when its arguments are rejected
+type FunctionError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+func (e *FunctionError) Error() string {
+	return e.Message
+}
+
+// Response wraps the error in the payload passed back to the model
+func (e *FunctionError) Response() map[string]any {
+	return map[string]any{"error": e}
+}
+
+// TicketPurchase holds the purchase details selected by the user until the checkout link is generated
+type TicketPurchase struct {
+	EventID   int    `json:"eventId"`
+	TierName  string `json:"tierName"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unitPrice"`
+	Total     Money  `json:"total"`
+}
+
+func (p TicketPurchase) MarshalBinary() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON decodes the prices of a tier in its currency, so they keep all of its decimal places
+func (t *TicketTier) UnmarshalJSON(data []byte) error {
+	type tier TicketTier
+	raw := struct {
+		*tier
+		Price         json.RawMessage `json:"price"`
+		DiscountPrice json.RawMessage `json:"discountPrice"`
+	}{tier: (*tier)(t)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	currency := t.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	t.Price = Money{}
+	if len(raw.Price) > 0 {
+		if err := t.Price.decode(raw.Price, currency); err != nil {
+			return err
+		}
+	}
+
+	t.DiscountPrice = nil
+	if len(raw.DiscountPrice) > 0 && string(raw.DiscountPrice) != "null" {
+		t.DiscountPrice = &Money{}
+		if err := t.DiscountPrice.decode(raw.DiscountPrice, currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DiscountActive reports whether the discounted price of a tier can still be bought
+func (t *TicketTier) DiscountActive(now time.Time) bool {
+	if !t.Discount || t.DiscountPrice == nil {
+		return false
+	}
+
+	if t.DiscountStatus != nil && *t.DiscountStatus != "ACTIVE" {
+		return false
+	}
+
+	if t.DiscountExpiration != nil && !t.DiscountExpiration.After(now) {
+		return false
+	}
+
+	return t.NumberOfDiscountTickets == nil || *t.NumberOfDiscountTickets > 0
+}
+
+// UnitPrice returns the discounted price of a tier while the discount is active, otherwise the regular price
+func (t *TicketTier) UnitPrice(now time.Time) Money {
+	if t.DiscountActive(now) {
+		return *t.DiscountPrice
+	}
+
+	return t.Price
+}
+
+// LineTotal returns the price of a quantity of tickets. Tickets beyond the remaining
+// discounted tickets are charged at the regular price.
+func (t *TicketTier) LineTotal(quantity int, now time.Time) Money {
+	if !t.DiscountActive(now) {
+		return t.Price.Mul(quantity)
+	}
+
+	discounted := quantity
+	if t.NumberOfDiscountTickets != nil && *t.NumberOfDiscountTickets < quantity {
+		discounted = *t.NumberOfDiscountTickets
+	}
+
+	total := t.DiscountPrice.Mul(discounted)
+	total.Amount += t.Price.Mul(quantity - discounted).Amount
+
+	return total
+}
+
+// Remaining returns the number of tickets left in a tier, and false if it is unknown. The total number
+// of tickets is used as an upper bound if the backend service does not report the remaining tickets.
+func (t *TicketTier) Remaining() (int, bool) {
+	switch {
+	case t.SoldOut:
+		return 0, true
+	case t.RemainingTickets != nil:
+		return *t.RemainingTickets, true
+	case t.TotalNumberOfTickets > 0:
+		return t.TotalNumberOfTickets, true
+	default:
+		return 0, false
+	}
+}
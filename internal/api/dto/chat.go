@@ -1,6 +1,10 @@
 package dto
 
-import "google.golang.org/genai"
+import (
+	"encoding/json"
+
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+)
 
 type ConversationState int
 
@@ -31,6 +35,13 @@ func (s ConversationState) String() string {
 }
 
 type ConversationContext struct {
-	Content      *genai.Content    `json:"content"`
-	CurrentState ConversationState `json:"current_state"`
+	Message       *llm.Message      `json:"message"`
+	CurrentState  ConversationState `json:"current_state"`
+	Summary       bool              `json:"summary,omitempty"` // Set if the message is a summary of older turns
+	PromptVersion string            `json:"prompt_version,omitempty"`
+}
+
+// MarshalBinary encodes the context as JSON so it can be stored in Redis lists
+func (c *ConversationContext) MarshalBinary() ([]byte, error) {
+	return json.Marshal(c)
 }
@@ -0,0 +1,118 @@
+package dto
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Arguments of each function declared to the model. The JSON fields must match the parameters of the
+// function declarations, which is checked against the loaded declarations at startup.
+
+type FindEventsArgs struct {
+	Categories      []string `json:"categories,omitempty"`
+	StartDate       string   `json:"startDate,omitempty"`
+	EndDate         string   `json:"endDate,omitempty"`
+	EventTitle      string   `json:"eventTitle,omitempty"`
+	Location        string   `json:"location,omitempty"`
+	Venue           string   `json:"venue,omitempty"`
+	NumberOfQueries int      `json:"numberOfQueries"`
+}
+
+type FindNearbyEventsArgs struct {
+	Place    string  `json:"place,omitempty"`
+	RadiusKm float64 `json:"radiusKm,omitempty"`
+}
+
+type FindTrendingEventsArgs struct{}
+
+type SelectEventArgs struct {
+	EventID int `json:"eventId"`
+}
+
+type SelectTicketTierArgs struct {
+	EventID  int    `json:"eventId"`
+	TierName string `json:"tierName"`
+	Quantity int    `json:"quantity"`
+}
+
+type InitiateTicketPurchaseArgs struct {
+	Email string `json:"email"`
+}
+
+// NewFunctionArgs returns a pointer to the args struct of a function, or false if the function is unknown
+func NewFunctionArgs(name string) (any, bool) {
+	switch name {
+	case FindEvents.String():
+		return &FindEventsArgs{}, true
+	case FindNearbyEvents.String():
+		return &FindNearbyEventsArgs{}, true
+	case FindTrendingEvents.String():
+		return &FindTrendingEventsArgs{}, true
+	case SelectEvent.String():
+		return &SelectEventArgs{}, true
+	case SelectTicketTier.String():
+		return &SelectTicketTierArgs{}, true
+	case InitiateTicketPurchase.String():
+		return &InitiateTicketPurchaseArgs{}, true
+	default:
+		return nil, false
+	}
+}
+
+func (a *FindEventsArgs) Validate() error {
+	// Pagination starts from the first page
+	if a.NumberOfQueries < 1 {
+		a.NumberOfQueries = 1
+	}
+
+	return nil
+}
+
+// Largest search radius the backend service supports for nearby events
+const MaxNearbyRadiusKm = 100
+
+func (a *FindNearbyEventsArgs) Validate() error {
+	a.Place = strings.TrimSpace(a.Place)
+
+	if a.RadiusKm < 0 || a.RadiusKm > MaxNearbyRadiusKm {
+		return &FunctionError{
+			Code:    "INVALID_ARGUMENT",
+			Message: fmt.Sprintf("Search radius must be between 1 and %d km", MaxNearbyRadiusKm),
+			Field:   "radiusKm",
+		}
+	}
+
+	return nil
+}
+
+func (a *SelectEventArgs) Validate() error {
+	if a.EventID < 1 {
+		return &FunctionError{Code: "INVALID_ARGUMENT", Message: "Event ID must be a positive number", Field: "eventId"}
+	}
+
+	return nil
+}
+
+func (a *SelectTicketTierArgs) Validate() error {
+	if a.EventID < 1 {
+		return &FunctionError{Code: "INVALID_ARGUMENT", Message: "Event ID must be a positive number", Field: "eventId"}
+	}
+
+	if strings.TrimSpace(a.TierName) == "" {
+		return &FunctionError{Code: "MISSING_ARGUMENT", Message: "Ticket tier name is required", Field: "tierName"}
+	}
+
+	return nil
+}
+
+func (a *InitiateTicketPurchaseArgs) Validate() error {
+	a.Email = strings.TrimSpace(a.Email)
+
+	address, err := mail.ParseAddress(a.Email)
+	if err != nil || address.Address != a.Email || !strings.Contains(address.Address[strings.LastIndex(address.Address, "@"):], ".") {
+		return &FunctionError{Code: "INVALID_ARGUMENT", Message: fmt.Sprintf("%q is not a valid email address", a.Email), Field: "email"}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Currency of amounts the backend service sends without one
+const DefaultCurrency = "NGN"
+
+type currencyFormat struct {
+	symbol   string
+	thousand string
+	decimal  string
+}
+
+// Formatting conventions of the currencies users are most likely to pay in
+var currencyFormats = map[string]currencyFormat{
+	"NGN": {"₦", ",", "."},
+	"GHS": {"GH₵", ",", "."},
+	"KES": {"KSh", ",", "."},
+	"ZAR": {"R", " ", ","},
+	"USD": {"$", ",", "."},
+	"GBP": {"£", ",", "."},
+	"EUR": {"€", ".", ","},
+}
+
+// Number of decimal places (ISO 4217 exponent) of currencies with more or fewer than two
+var currencyExponents = map[string]int{
+	"XOF": 0, "XAF": 0, "RWF": 0, "UGX": 0, "BIF": 0, "DJF": 0, "GNF": 0, "KMF": 0,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "PYG": 0, "ISK": 0, "VUV": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// exponent returns the number of decimal places of a currency, which is two for most currencies
+func exponent(currency string) int {
+	if e, ok := currencyExponents[currency]; ok {
+		return e
+	}
+
+	return 2
+}
+
+// CurrencyMarkers returns the codes and symbols of the currencies with known conventions, by which
+// amounts are written in text
+func CurrencyMarkers() (codes, symbols []string) {
+	for code, format := range currencyFormats {
+		codes = append(codes, code)
+		symbols = append(symbols, strings.TrimSpace(format.symbol))
+	}
+
+	for code := range currencyExponents {
+		if _, ok := currencyFormats[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+
+	sort.Strings(codes)
+	sort.Strings(symbols)
+
+	return codes, symbols
+}
+
+// Money is an amount in the minor units of a currency (e.g. kobo for NGN), so prices and totals are exact.
+// Currencies without minor units (e.g. XOF) are counted in major units.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney converts an amount in major units (e.g. naira) to Money
+func NewMoney(major float64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	currency = strings.ToUpper(currency)
+	scale := math.Pow10(exponent(currency))
+
+	return Money{Amount: int64(math.Round(major * scale)), Currency: currency}
+}
+
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(exponent(m.Currency))
+}
+
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+// String formats the amount by the conventions of its currency, e.g. ₦25,000.00 or XOF 5,000
+func (m Money) String() string {
+	format, ok := currencyFormats[m.Currency]
+	if !ok {
+		format = currencyFormat{symbol: m.Currency + " ", thousand: ",", decimal: "."}
+	}
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := exponent(m.Currency)
+	scale := int64(math.Pow10(digits))
+	whole := strconv.FormatInt(amount/scale, 10)
+
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(format.thousand)
+		}
+		b.WriteRune(digit)
+	}
+
+	if digits == 0 {
+		return sign + format.symbol + b.String()
+	}
+
+	return fmt.Sprintf("%s%s%s%s%0*d", sign, format.symbol, b.String(), format.decimal, digits, amount%scale)
+}
+
+type moneyJSON struct {
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Formatted string  `json:"formatted,omitempty"`
+}
+
+// MarshalJSON encodes the amount in major units with its formatted value, which is what the model quotes
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Major(), Currency: m.Currency, Formatted: m.String()})
+}
+
+// UnmarshalJSON accepts a number or numeric string in major units of the default currency,
+// as sent by the backend service, or the object produced by MarshalJSON.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return m.decode(data, DefaultCurrency)
+}
+
+// decode parses an amount in major units of the given currency, or the object produced by MarshalJSON
+func (m *Money) decode(data []byte, currency string) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		*m = Money{}
+	case float64:
+		*m = NewMoney(v, currency)
+	case string:
+		major, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(v), ",", ""), 64)
+		if err != nil {
+			return fmt.Errorf("Error parsing amount %q: %s", v, err.Error())
+		}
+		*m = NewMoney(major, currency)
+	case map[string]any:
+		var obj moneyJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		*m = NewMoney(obj.Amount, obj.Currency)
+	default:
+		return fmt.Errorf("Error parsing amount: Unsupported value %s", string(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyExponent(t *testing.T) {
+	tests := []struct {
+		major    float64
+		currency string
+		amount   int64
+		text     string
+	}{
+		{25000, "NGN", 2500000, "₦25,000.00"},
+		{99.99, "usd", 9999, "$99.99"},
+		{5000, "XOF", 5000, "XOF 5,000"},
+		{3500, "RWF", 3500, "RWF 3,500"},
+		{12.5, "KWD", 12500, "KWD 12.500"},
+		{1500.75, "MUR", 150075, "MUR 1,500.75"},
+		{-10, "XAF", -10, "-XAF 10"},
+	}
+
+	for _, tt := range tests {
+		m := NewMoney(tt.major, tt.currency)
+
+		if m.Amount != tt.amount {
+			t.Errorf("NewMoney(%v, %s).Amount = %d, want %d", tt.major, tt.currency, m.Amount, tt.amount)
+		}
+
+		if m.Major() != tt.major {
+			t.Errorf("NewMoney(%v, %s).Major() = %v, want %v", tt.major, tt.currency, m.Major(), tt.major)
+		}
+
+		if m.String() != tt.text {
+			t.Errorf("NewMoney(%v, %s).String() = %q, want %q", tt.major, tt.currency, m.String(), tt.text)
+		}
+	}
+}
+
+func TestTicketTierCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		price    Money
+		discount *Money
+	}{
+		{
+			name:     "zero decimal currency",
+			data:     `{"name": "Regular", "price": 5000, "discount": true, "discountPrice": 4500, "currency": "XOF"}`,
+			price:    Money{Amount: 5000, Currency: "XOF"},
+			discount: &Money{Amount: 4500, Currency: "XOF"},
+		},
+		{
+			name:     "three decimal currency",
+			data:     `{"name": "Regular", "price": 1.234, "discount": true, "discountPrice": "0.995", "currency": "KWD"}`,
+			price:    Money{Amount: 1234, Currency: "KWD"},
+			discount: &Money{Amount: 995, Currency: "KWD"},
+		},
+		{
+			name:  "default currency",
+			data:  `{"name": "Regular", "price": "2,500.50", "discountPrice": null}`,
+			price: Money{Amount: 250050, Currency: "NGN"},
+		},
+		{
+			name:  "currency before price",
+			data:  `{"currency": "KWD", "name": "Regular", "price": 12.345}`,
+			price: Money{Amount: 12345, Currency: "KWD"},
+		},
+		{
+			name:  "rendered price",
+			data:  `{"name": "Regular", "price": {"amount": 1.234, "currency": "KWD", "formatted": "KWD 1.234"}, "currency": "KWD"}`,
+			price: Money{Amount: 1234, Currency: "KWD"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tier TicketTier
+			if err := json.Unmarshal([]byte(tt.data), &tier); err != nil {
+				t.Fatalf("Error parsing ticket tier: %s", err.Error())
+			}
+
+			if tier.Price != tt.price {
+				t.Errorf("Price = %+v, want %+v", tier.Price, tt.price)
+			}
+
+			if (tier.DiscountPrice == nil) != (tt.discount == nil) || (tt.discount != nil && *tier.DiscountPrice != *tt.discount) {
+				t.Errorf("DiscountPrice = %+v, want %+v", tier.DiscountPrice, tt.discount)
+			}
+		})
+	}
+}
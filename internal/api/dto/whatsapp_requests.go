@@ -70,12 +70,13 @@ type MessageRequestPayload struct {
 	To               *string `json:"to,omitempty"`
 	MessageID        *string `json:"message_id,omitempty"`
 	Status           *string `json:"status,omitempty"` // Set to "read"
-	Type             *string `json:"type,omitempty"`   // Set to either "text" or "interactive"
+	Type             *string `json:"type,omitempty"`   // Set to either "text", "interactive" or "template"
 	Context          *struct {
 		MessageID string `json:"message_id"`
 	} `json:"context,omitempty"`
 	Text            *ReplyText        `json:"text,omitempty"`
 	Interactive     *ReplyInteractive `json:"interactive,omitempty"`
+	Template        *ReplyTemplate    `json:"template,omitempty"`
 	TypingIndicator *struct {
 		Type string `json:"type"` // Set to "text"
 	} `json:"typing_indicator,omitempty"`
@@ -105,6 +106,11 @@ func (s ReplyInteractiveType) String() string {
 	return types[s]
 }
 
+// MarshalText encodes the type by its name, as expected by the Whatsapp Cloud API
+func (s ReplyInteractiveType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
 type ReplyInteractive struct {
 	Type   ReplyInteractiveType    `json:"type"`
 	Header *ReplyInteractiveHeader `json:"header,omitempty"`
@@ -133,3 +139,23 @@ type ReplyInteractiveAction struct {
 	Name    *string                  `json:"name,omitempty"`
 	Buttons []ReplyInteractiveButton `json:"buttons,omitempty"`
 }
+
+// ReplyTemplate is a message template approved by WhatsApp. Templates are the only messages that can be
+// sent to a user outside the 24 hour customer service window that follows the user's last message.
+type ReplyTemplate struct {
+	Name     string `json:"name"`
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	Components []ReplyTemplateComponent `json:"components,omitempty"`
+}
+
+type ReplyTemplateComponent struct {
+	Type       string                   `json:"type"` // Set to "body"
+	Parameters []ReplyTemplateParameter `json:"parameters"`
+}
+
+type ReplyTemplateParameter struct {
+	Type string `json:"type"` // Set to "text"
+	Text string `json:"text"`
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/tasks"
+)
+
+func (h *RouteHandler) InvalidateEventsCache(c *gin.Context) {
+	rawBody, ok := h.readSignedBody(c, "Cache invalidation request")
+	if !ok {
+		return
+	}
+
+	var body dto.CacheInvalidationPayload
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Services.Context.InvalidateEvents(c.Request.Context(), body.EventIDs...); err != nil {
+		log.Error().Err(err).Msg("Error invalidating cached events")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.String(http.StatusOK, "Events cache invalidated")
+}
+
+func (h *RouteHandler) HandleEventUpdate(c *gin.Context) {
+	rawBody, ok := h.readSignedBody(c, "Event notification")
+	if !ok {
+		return
+	}
+
+	var body dto.EventWebhookPayload
+	if err := json.Unmarshal(rawBody, &body); err != nil || body.EventID < 1 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	switch body.Type {
+	case "updated", "cancelled", "sold_out":
+	default:
+		log.Warn().Str("type", body.Type).Msg("Unknown event notification type received")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Stop serving stale details of the event before users are notified
+	if err := h.Services.Context.InvalidateEvents(ctx, body.EventID); err != nil {
+		log.Error().Err(err).Int("event_id", body.EventID).Msg("Error invalidating cached event")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// Add webhook payload to queue for notifying users
+	task, err := tasks.NewEventUpdateTask(body, c.GetHeader("x-webhook-timestamp"))
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating new event update task worker")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.TasksQueue.EnqueueContext(ctx, task)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		log.Warn().Int("event_id", body.EventID).Msg("Event notification is already queued for processing")
+		c.String(http.StatusOK, "Duplicate notification")
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("Error adding event update payload to queue for processing")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.String(http.StatusOK, "Event notification processed")
+}
@@ -60,7 +60,7 @@ func (h *RouteHandler) HandleIncomingMessage(c *gin.Context) {
 
 	message := entry.Changes[0].Value.Messages[0]
 
-	err := h.Services.Message.HandleIncomingMessage(message)
+	err := h.Services.Message.HandleIncomingMessage(c.Request.Context(), message)
 	if err != nil {
 		log.Error().Err(err).Msg("Error processing incoming message")
 		c.Status(http.StatusOK)
@@ -1,57 +1,46 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
 	"github.com/rs/zerolog/log"
 	"github.com/xerdin442/ticketing-bot/internal/api/dto"
 	"github.com/xerdin442/ticketing-bot/internal/tasks"
 )
 
 func (h *RouteHandler) CheckPaymentStatus(c *gin.Context) {
-	var body dto.PaymentWebhookPayload
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.Status(http.StatusBadRequest)
+	rawBody, ok := h.readSignedBody(c, "Payment notification")
+	if !ok {
 		return
 	}
 
-	// Compute signature hash
-	dataToSign := fmt.Sprintf("%q", body.Reference)
-	hash := hmac.New(sha256.New, []byte(h.Env.BackendServiceApiKey))
-	hash.Write([]byte(dataToSign))
-	signature := hex.EncodeToString(hash.Sum(nil))
-
-	// Verify webhook request signature
-	receivedSignature := c.GetHeader("x-webhook-signature")
-	if receivedSignature == "" {
-		log.Warn().Msg("Payment notification missing signature header")
-		c.Status(http.StatusUnauthorized)
+	var body dto.PaymentWebhookPayload
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		c.Status(http.StatusBadRequest)
 		return
 	}
 
-	if signature != receivedSignature {
-		log.Warn().Msg("Payment notification signature mismatch")
-		c.Status(http.StatusUnauthorized)
+	if !body.Status.Valid() {
+		log.Warn().Str("status", string(body.Status)).Msg("Unknown payment status received")
+		c.Status(http.StatusBadRequest)
 		return
 	}
 
 	ctx := c.Request.Context()
-	cacheKey := fmt.Sprintf("payment_notification:%s", body.Reference)
 
-	// Verify notification ID to ensure idempotent procssing
-	exists, err := h.Cache.Exists(ctx, cacheKey).Result()
+	// Claim the notification atomically, so concurrent duplicates are not processed twice
+	claimed, err := tasks.ClaimPaymentNotification(ctx, h.Cache, body)
 	if err != nil {
-		log.Error().Err(err).Msg("Error fetching idempotency key from cache")
+		log.Error().Err(err).Msg("Error claiming payment notification")
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	if exists > 0 {
+	if !claimed {
 		log.Warn().Str("reference", body.Reference).Msg("Duplicate payment notification received")
 		c.String(http.StatusOK, "Duplicate notification")
 		return
@@ -65,8 +54,18 @@ func (h *RouteHandler) CheckPaymentStatus(c *gin.Context) {
 		return
 	}
 
-	if _, err := h.TasksQueue.EnqueueContext(ctx, task); err != nil {
+	_, err = h.TasksQueue.EnqueueContext(ctx, task)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		log.Warn().Str("reference", body.Reference).Msg("Payment notification is already queued for processing")
+		c.String(http.StatusOK, "Duplicate notification")
+		return
+	} else if err != nil {
 		log.Error().Err(err).Msg("Error adding payment webhook payload to queue for processing")
+
+		if err := tasks.ReleasePaymentNotification(ctx, h.Cache, body); err != nil {
+			log.Error().Err(err).Msg("Error releasing payment notification")
+		}
+
 		c.Status(http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// readSignedBody reads the raw body of a webhook request from the backend service and verifies its signature.
+// The signature is an HMAC-SHA256 of the timestamp header and the raw body joined by a dot, so neither can be
+// changed or replayed outside the tolerance window. The response status is set if the request is rejected.
+func (h *RouteHandler) readSignedBody(c *gin.Context, requestName string) ([]byte, bool) {
+	rawBody, err := c.GetRawData()
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, false
+	}
+
+	receivedSignature := c.GetHeader("x-webhook-signature")
+	timestamp := c.GetHeader("x-webhook-timestamp")
+	if receivedSignature == "" || timestamp == "" {
+		log.Warn().Msg(requestName + " missing signature or timestamp header")
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Reject requests signed too long ago, or too far in the future
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		log.Warn().Str("timestamp", timestamp).Msg(requestName + " has an invalid timestamp")
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if skew := math.Abs(float64(time.Now().Unix() - sentAt)); skew > float64(h.Env.WebhookTolerance) {
+		log.Warn().Int64("timestamp", sentAt).Msg(requestName + " timestamp is outside the tolerance window")
+		c.Status(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// The previous secret is accepted while the backend service is rotated to a new one
+	for _, secret := range []string{h.Env.WebhookSecret, h.Env.WebhookSecretPrevious} {
+		if secret != "" && validSignature(secret, timestamp, rawBody, receivedSignature) {
+			return rawBody, true
+		}
+	}
+
+	log.Warn().Msg(requestName + " signature mismatch")
+	c.Status(http.StatusUnauthorized)
+	return nil, false
+}
+
+func validSignature(secret, timestamp string, body []byte, receivedSignature string) bool {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(timestamp + "."))
+	hash.Write(body)
+	signature := hex.EncodeToString(hash.Sum(nil))
+
+	return hmac.Equal([]byte(signature), []byte(receivedSignature))
+}
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is a circuit breaker that stops requests to the backend service after consecutive failures.
+// Once the cooldown has passed, a single trial request is let through to check if it has recovered.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a request may be sent to the backend service
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// Only the trial request is let through until it completes
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a request that was allowed through
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			log.Error().Int("failures", b.failures).Dur("cooldown", b.cooldown).Msg("Backend service circuit breaker opened")
+		}
+
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon releases a request that ended without an outcome, e.g. because the caller cancelled it.
+// A trial request is abandoned by reopening the breaker, so the next request becomes the trial.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of failure a request to the backend service can end in. Errors returned by the client
+// match one of these with errors.Is, unless the backend responded with an unexpected status.
+var (
+	ErrNotFound    = errors.New("resource not found")
+	ErrValidation  = errors.New("request rejected by validation")
+	ErrUnavailable = errors.New("backend service unavailable")
+)
+
+// Error is returned when a request to the backend service fails
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int // Zero if no response was received
+	Message    string
+	kind       error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("Backend request %s %s failed. Error: %s", e.Method, e.Path, e.Message)
+	}
+
+	return fmt.Sprintf("Backend request %s %s failed. Error: %s Status code: %d", e.Method, e.Path, e.Message, e.StatusCode)
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// kindOf classifies an error status returned by the backend service
+func kindOf(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound || statusCode == http.StatusGone:
+		return ErrNotFound
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusConflict || statusCode == http.StatusUnprocessableEntity:
+		return ErrValidation
+	case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Deadline for a single attempt of a request
+	requestTimeout = 10 * time.Second
+	// Maximum attempts of an idempotent request when the backend service is unavailable
+	maxAttempts = 3
+	// Consecutive failures that open the circuit breaker, and how long it stays open
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+	// Longest error body read from a failed response
+	maxErrorBodyBytes = 4 << 10
+)
+
+// Client sends requests to the backend service. GET requests are retried when the backend service
+// is unavailable, and requests fail fast while the circuit breaker is open.
+type Client struct {
+	baseUrl    string
+	apiKey     string
+	httpClient *http.Client
+	breaker    *breaker
+}
+
+func NewClient(baseUrl, apiKey string) *Client {
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		breaker:    newBreaker(breakerThreshold, breakerCooldown),
+	}
+}
+
+// Do sends a request to the backend service and decodes the JSON response body into out
+func (c *Client) Do(ctx context.Context, method, path string, body []byte, out any) error {
+	attempts := 1
+	if method == http.MethodGet || method == http.MethodHead {
+		attempts = maxAttempts
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if !c.breaker.allow() {
+			return &Error{Method: method, Path: path, Message: "circuit breaker is open", kind: ErrUnavailable}
+		}
+
+		err = c.send(ctx, method, path, body, out)
+
+		// Stop trying if the caller is no longer waiting for a response
+		if ctx.Err() != nil {
+			c.breaker.abandon()
+			return ctx.Err()
+		}
+
+		unavailable := errors.Is(err, ErrUnavailable)
+		c.breaker.record(unavailable)
+
+		if !unavailable || attempt == attempts {
+			break
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Msg("Backend service unavailable. Retrying request")
+
+		if err := sleep(ctx, backoff(attempt)); err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// Configure request details
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, reader)
+	if err != nil {
+		return fmt.Errorf("Error configuring new HTTP request: %s", err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	// Send request to backend service. Network errors and timeouts mean the backend is unavailable
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &Error{Method: method, Path: path, Message: err.Error(), kind: ErrUnavailable}
+	}
+	defer resp.Body.Close()
+
+	// Check the status before decoding, since error responses may not be JSON
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(resp),
+			kind:       kindOf(resp.StatusCode),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("Error decoding response body from backend service: %s", err.Error())
+	}
+
+	return nil
+}
+
+// errorMessage extracts the message of a failed response, falling back to the status text
+func errorMessage(resp *http.Response) string {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+
+	var body struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &body); err == nil {
+		if body.Message != "" {
+			return body.Message
+		} else if body.Error != "" {
+			return body.Error
+		}
+	}
+
+	if text := strings.TrimSpace(string(raw)); text != "" && !strings.HasPrefix(text, "<") {
+		return text
+	}
+
+	return http.StatusText(resp.StatusCode)
+}
+
+// backoff returns the exponential delay with jitter before the given retry attempt
+func backoff(attempt int) time.Duration {
+	base := 200 * time.Millisecond << (attempt - 1)
+	jitter := time.Duration(rand.Int64N(int64(base / 2)))
+
+	return base + jitter
+}
+
+// sleep waits for the delay unless the context is cancelled first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
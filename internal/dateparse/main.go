@@ -0,0 +1,488 @@
+package dateparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is an inclusive range of calendar days. Start and End are set to midnight.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (r Range) StartDate() string {
+	return r.Start.Format("2006-01-02")
+}
+
+func (r Range) EndDate() string {
+	return r.End.Format("2006-01-02")
+}
+
+// rule resolves a matched phrase relative to the current day
+type rule struct {
+	pattern *regexp.Regexp
+	resolve func(m []string, today time.Time) (Range, bool)
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+}
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+const (
+	monthPattern   = `(jan|january|feb|february|mar|march|apr|april|may|jun|june|jul|july|aug|august|sep|sept|september|oct|october|nov|november|dec|december)`
+	dayPattern     = `(\d{1,2})(?:st|nd|rd|th)?`
+	yearPattern    = `(?:,? (\d{4}))?`
+	weekdayPattern = `(monday|tuesday|wednesday|thursday|friday|saturday|sunday)`
+	countPattern   = `(\d{1,2}|a|an|one|two|three|four|five|six|seven|eight|nine|ten)`
+	connector      = ` ?(?:to|till|until|through|and|-) ?`
+
+	// Words that follow "may" when it is not the month, e.g. "in may i ask"
+	modalPattern = `(i|we|you|he|she|they|it|be|not|have|sound|need|want)`
+	// Words that follow a month when it is the date of an event, e.g. "for december shows"
+	monthContextPattern = `(?:events?|shows?|concerts?|parties|party|festivals?|gigs?|plans?|outings?|hangouts?|holidays?|vacation|break|trip|weekends?)`
+)
+
+// Rules are tried in order, so ranges and longer phrases come before the phrases they contain
+var rules = []rule{
+	// "dec 5 to dec 10", "5th dec - 10th dec"
+	{re(`(?:` + monthPattern + ` ` + dayPattern + `|` + dayPattern + ` (?:of )?` + monthPattern + `)` + yearPattern + connector +
+		`(?:` + monthPattern + ` ` + dayPattern + `|` + dayPattern + ` (?:of )?` + monthPattern + `)` + yearPattern), explicitRange},
+	// "5th to 10th december", "5 - 10 dec"
+	{re(dayPattern + connector + dayPattern + ` (?:of )?` + monthPattern + yearPattern), sharedMonthRange},
+	// "december 5 to 10", "dec 5th - 10th"
+	{re(monthPattern + ` ` + dayPattern + connector + dayPattern + `\b` + yearPattern), sharedMonthRangeMonthFirst},
+	// "5/12 to 10/12"
+	{re(`(\d{1,2})/(\d{1,2})(?:/(\d{4}))?` + connector + `(\d{1,2})/(\d{1,2})(?:/(\d{4}))?`), numericRange},
+
+	// "2026-12-05"
+	{re(`(\d{4})-(\d{1,2})-(\d{1,2})`), isoDate},
+	// "05/12/2026" (day before month)
+	{re(`(\d{1,2})/(\d{1,2})/(\d{4})`), numericDate},
+	// "on 5/12", "5/12". Without a year, the date must be the whole message or follow a word such
+	// as "on", so that numbers like "1/2 price" are not read as dates
+	{re(`(?:on|from|by|for|before|after|till|until|starting|date) (\d{1,2})/(\d{1,2})`), numericDayMonth},
+	{re(`^(\d{1,2})/(\d{1,2})$`), numericDayMonth},
+	// "december 5th", "dec 5, 2026"
+	{re(monthPattern + ` ` + dayPattern + `\b` + yearPattern), monthFirstDate},
+	// "5th december", "5th of dec 2026"
+	{re(dayPattern + ` (?:of )?` + monthPattern + yearPattern), dayFirstDate},
+
+	// Holidays and seasons
+	{re(`christmas eve|xmas eve`), fixedDay(time.December, 24)},
+	{re(`christmas|xmas|x-mas`), fixedDay(time.December, 25)},
+	{re(`boxing day`), fixedDay(time.December, 26)},
+	{re(`new year'?s eve|crossover night|crossover`), fixedDay(time.December, 31)},
+	{re(`new year'?s day|new year`), fixedDay(time.January, 1)},
+	{re(`detty december|detty dec`), fixedMonth(time.December)},
+	{re(`yuletide|festive season|festive period`), fixedSpan(time.December, 20, time.December, 31)},
+	{re(`ember months`), fixedSpan(time.September, 1, time.December, 31)},
+
+	// Relative days, including common Nigerian phrasing such as "next tomorrow"
+	{re(`day after tomorrow|day after tomorow|next tomorrow|next tomorow|next tmrw|next tmr`), offsetDay(2)},
+	{re(`tomorrow|tomorow|tommorow|tmrw|tmr|2moro|2morrow|tumoro`), offsetDay(1)},
+	{re(`today|tonight|this evening|this night|this afternoon|later today`), offsetDay(0)},
+
+	// Weekends run from Friday to Sunday
+	{re(`weekend after next`), weekend(2)},
+	{re(`next weekend|weekend after this`), weekend(1)},
+	{re(`this weekend|over the weekend|weekend wey dey come|coming weekend|the weekend|weekend`), weekend(0)},
+
+	// Weeks run from Monday to Sunday
+	{re(`next ` + countPattern + ` weeks|coming ` + countPattern + ` weeks`), upcomingWeeks},
+	{re(`next week|coming week|following week`), nextWeek},
+	{re(`this week|within the week|later this week|rest of the week|this wk`), thisWeek},
+	{re(`(?:in|within) ` + countPattern + ` weeks?|` + countPattern + ` weeks? from (?:now|today)`), offsetWeeks},
+	{re(`(?:in|within) ` + countPattern + ` days?|` + countPattern + ` days? from (?:now|today)`), offsetDays},
+
+	// Months
+	{re(`next month|coming month|following month`), monthOffset(1)},
+	{re(`end of (?:the )?month|month end|month-end`), monthEnd},
+	{re(`this month|rest of the month|within the month`), monthOffset(0)},
+
+	// Weekdays. "next friday" is the friday of next week, while "friday" is the coming one
+	{re(`next ` + weekdayPattern), nextWeekday},
+	{re(weekdayPattern), upcomingWeekday},
+
+	// A month on its own, e.g. "in december". After "for", "this" and "around" the month must end the
+	// message or be followed by an event word, since "this may sound odd" or "for march madness" are not dates
+	{re(`(?:in|during|throughout|next) ` + monthPattern + `(?: ` + modalPattern + `)?`), wholeMonth},
+	{re(`(?:for|this|around) ` + monthPattern + `(?: ` + monthContextPattern + `|$)`), wholeMonth},
+}
+
+var (
+	punctuation = regexp.MustCompile(`[^a-z0-9/'\- ]+`)
+	whitespace  = regexp.MustCompile(`\s+`)
+	// "24/7" means round the clock, not the 24th of July
+	roundTheClock = regexp.MustCompile(`(?:^| )24/7(?: |$)`)
+)
+
+func re(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`\b(?:` + pattern + `)\b`)
+}
+
+// Parse finds the first date phrase in the text and resolves it to a range of days relative to now.
+// Dates without a year are assumed to be the next occurrence of that date.
+func Parse(text string, now time.Time) (Range, bool) {
+	normalized := strings.ToLower(text)
+	normalized = strings.ReplaceAll(normalized, "’", "'")
+	normalized = punctuation.ReplaceAllString(normalized, " ")
+	normalized = roundTheClock.ReplaceAllString(normalized, " ")
+	normalized = whitespace.ReplaceAllString(strings.TrimSpace(normalized), " ")
+
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	for _, r := range rules {
+		m := r.pattern.FindStringSubmatch(normalized)
+		if m == nil {
+			continue
+		}
+
+		if result, ok := r.resolve(m, today); ok {
+			return result, true
+		}
+	}
+
+	return Range{}, false
+}
+
+func single(t time.Time) Range {
+	return Range{Start: t, End: t}
+}
+
+func date(year int, month time.Month, day int, today time.Time) (time.Time, bool) {
+	t := time.Date(year, month, day, 0, 0, 0, 0, today.Location())
+
+	// Reject overflowing dates such as February 30th
+	if t.Month() != month || t.Day() != day {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// nextOccurrence resolves a month and day without a year to its next occurrence
+func nextOccurrence(month time.Month, day int, yearStr string, today time.Time) (time.Time, bool) {
+	if yearStr != "" {
+		year, _ := strconv.Atoi(yearStr)
+		return date(year, month, day, today)
+	}
+
+	t, ok := date(today.Year(), month, day, today)
+	if ok && t.Before(today) {
+		return date(today.Year()+1, month, day, today)
+	}
+
+	return t, ok
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func count(s string) int {
+	if n, ok := numberWords[s]; ok {
+		return n
+	}
+
+	return atoi(s)
+}
+
+func ordered(start, end time.Time) (Range, bool) {
+	if end.Before(start) {
+		// A range that wraps over the new year, e.g. "dec 28 to jan 3"
+		if start.Month() > end.Month() && start.Year() == end.Year() {
+			return Range{Start: start, End: end.AddDate(1, 0, 0)}, true
+		}
+
+		return Range{}, false
+	}
+
+	return Range{Start: start, End: end}, true
+}
+
+func explicitRange(m []string, today time.Time) (Range, bool) {
+	// Groups: 1 month, 2 day | 3 day, 4 month | 5 year, then the same layout for the end date
+	startMonth, startDay := m[1], m[2]
+	if startMonth == "" {
+		startMonth, startDay = m[4], m[3]
+	}
+
+	endMonth, endDay := m[6], m[7]
+	if endMonth == "" {
+		endMonth, endDay = m[9], m[8]
+	}
+
+	startYear := m[5]
+	if startYear == "" {
+		startYear = m[10]
+	}
+
+	start, ok := nextOccurrence(months[startMonth], atoi(startDay), startYear, today)
+	if !ok {
+		return Range{}, false
+	}
+
+	end, ok := nextOccurrence(months[endMonth], atoi(endDay), m[10], today)
+	if !ok {
+		return Range{}, false
+	}
+
+	return ordered(start, end)
+}
+
+func sharedMonthRange(m []string, today time.Time) (Range, bool) {
+	month := months[m[3]]
+
+	start, ok := nextOccurrence(month, atoi(m[1]), m[4], today)
+	if !ok {
+		return Range{}, false
+	}
+
+	end, ok := date(start.Year(), month, atoi(m[2]), today)
+	if !ok {
+		return Range{}, false
+	}
+
+	return ordered(start, end)
+}
+
+func sharedMonthRangeMonthFirst(m []string, today time.Time) (Range, bool) {
+	return sharedMonthRange([]string{m[0], m[2], m[3], m[1], m[4]}, today)
+}
+
+func numericRange(m []string, today time.Time) (Range, bool) {
+	start, ok := numericDate([]string{m[0], m[1], m[2], m[3]}, today)
+	if !ok {
+		return Range{}, false
+	}
+
+	endYear := m[6]
+	if endYear == "" {
+		endYear = m[3]
+	}
+
+	end, ok := numericDate([]string{m[0], m[4], m[5], endYear}, today)
+	if !ok {
+		return Range{}, false
+	}
+
+	return ordered(start.Start, end.Start)
+}
+
+func isoDate(m []string, today time.Time) (Range, bool) {
+	t, ok := date(atoi(m[1]), time.Month(atoi(m[2])), atoi(m[3]), today)
+	return single(t), ok
+}
+
+func numericDate(m []string, today time.Time) (Range, bool) {
+	day, month := atoi(m[1]), atoi(m[2])
+	if month < 1 || month > 12 {
+		return Range{}, false
+	}
+
+	t, ok := nextOccurrence(time.Month(month), day, m[3], today)
+	return single(t), ok
+}
+
+func numericDayMonth(m []string, today time.Time) (Range, bool) {
+	return numericDate([]string{m[0], m[1], m[2], ""}, today)
+}
+
+func monthFirstDate(m []string, today time.Time) (Range, bool) {
+	t, ok := nextOccurrence(months[m[1]], atoi(m[2]), m[3], today)
+	return single(t), ok
+}
+
+func dayFirstDate(m []string, today time.Time) (Range, bool) {
+	t, ok := nextOccurrence(months[m[2]], atoi(m[1]), m[3], today)
+	return single(t), ok
+}
+
+func fixedDay(month time.Month, day int) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		t, ok := nextOccurrence(month, day, "", today)
+		return single(t), ok
+	}
+}
+
+func fixedSpan(startMonth time.Month, startDay int, endMonth time.Month, endDay int) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		year := today.Year()
+		end, _ := date(year, endMonth, endDay, today)
+		if end.Before(today) {
+			year++
+			end, _ = date(year, endMonth, endDay, today)
+		}
+
+		start, _ := date(year, startMonth, startDay, today)
+		if start.Before(today) {
+			start = today
+		}
+
+		return Range{Start: start, End: end}, true
+	}
+}
+
+func fixedMonth(month time.Month) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		return monthRange(month, today), true
+	}
+}
+
+// monthRange resolves a month to its next occurrence. The current month starts from today.
+func monthRange(month time.Month, today time.Time) Range {
+	year := today.Year()
+	if month < today.Month() {
+		year++
+	}
+
+	start := time.Date(year, month, 1, 0, 0, 0, 0, today.Location())
+	end := start.AddDate(0, 1, -1)
+	if start.Before(today) {
+		start = today
+	}
+
+	return Range{Start: start, End: end}
+}
+
+func wholeMonth(m []string, today time.Time) (Range, bool) {
+	// "may" followed by a pronoun or verb is not the month
+	if m[1] == "may" && len(m) > 2 && m[2] != "" {
+		return Range{}, false
+	}
+
+	return monthRange(months[m[1]], today), true
+}
+
+func offsetDay(days int) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		return single(today.AddDate(0, 0, days)), true
+	}
+}
+
+func offsetDays(m []string, today time.Time) (Range, bool) {
+	n := count(m[1])
+	if m[1] == "" {
+		n = count(m[2])
+	}
+
+	return single(today.AddDate(0, 0, n)), true
+}
+
+func offsetWeeks(m []string, today time.Time) (Range, bool) {
+	n := count(m[1])
+	if m[1] == "" {
+		n = count(m[2])
+	}
+
+	return single(today.AddDate(0, 0, 7*n)), true
+}
+
+// daysUntil returns the number of days from today to the next given weekday, including today
+func daysUntil(today time.Time, weekday time.Weekday) int {
+	return (int(weekday) - int(today.Weekday()) + 7) % 7
+}
+
+// startOfNextWeek returns the Monday after today
+func startOfNextWeek(today time.Time) time.Time {
+	days := daysUntil(today, time.Monday)
+	if days == 0 {
+		days = 7
+	}
+
+	return today.AddDate(0, 0, days)
+}
+
+func weekend(weeksAhead int) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		// On a weekend day, the current weekend is already underway
+		var start time.Time
+		switch today.Weekday() {
+		case time.Saturday:
+			start = today.AddDate(0, 0, -1)
+		case time.Sunday:
+			start = today.AddDate(0, 0, -2)
+		default:
+			start = today.AddDate(0, 0, daysUntil(today, time.Friday))
+		}
+
+		start = start.AddDate(0, 0, 7*weeksAhead)
+		end := start.AddDate(0, 0, 2)
+		if start.Before(today) {
+			start = today
+		}
+
+		return Range{Start: start, End: end}, true
+	}
+}
+
+func thisWeek(_ []string, today time.Time) (Range, bool) {
+	return Range{Start: today, End: startOfNextWeek(today).AddDate(0, 0, -1)}, true
+}
+
+func nextWeek(_ []string, today time.Time) (Range, bool) {
+	start := startOfNextWeek(today)
+	return Range{Start: start, End: start.AddDate(0, 0, 6)}, true
+}
+
+func upcomingWeeks(m []string, today time.Time) (Range, bool) {
+	n := count(m[1])
+	if m[1] == "" {
+		n = count(m[2])
+	}
+
+	return Range{Start: today, End: today.AddDate(0, 0, 7*n)}, true
+}
+
+func monthOffset(monthsAhead int) func([]string, time.Time) (Range, bool) {
+	return func(_ []string, today time.Time) (Range, bool) {
+		first := time.Date(today.Year(), today.Month()+time.Month(monthsAhead), 1, 0, 0, 0, 0, today.Location())
+		return monthRange(first.Month(), today), true
+	}
+}
+
+func monthEnd(_ []string, today time.Time) (Range, bool) {
+	end := time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location())
+
+	start := end.AddDate(0, 0, -6)
+	if start.Before(today) {
+		start = today
+	}
+
+	return Range{Start: start, End: end}, true
+}
+
+func nextWeekday(m []string, today time.Time) (Range, bool) {
+	start := startOfNextWeek(today)
+	return single(start.AddDate(0, 0, daysUntil(start, weekdays[m[1]]))), true
+}
+
+func upcomingWeekday(m []string, today time.Time) (Range, bool) {
+	return single(today.AddDate(0, 0, daysUntil(today, weekdays[m[1]]))), true
+}
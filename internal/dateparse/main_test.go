@@ -0,0 +1,164 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	// A Wednesday afternoon
+	wednesday = time.Date(2026, time.October, 14, 15, 4, 0, 0, time.UTC)
+	saturday  = time.Date(2026, time.October, 17, 10, 0, 0, 0, time.UTC)
+	sunday    = time.Date(2026, time.October, 18, 21, 30, 0, 0, time.UTC)
+	lateDec   = time.Date(2026, time.December, 30, 9, 0, 0, 0, time.UTC)
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		now   time.Time
+		start string
+		end   string
+		ok    bool
+	}{
+		// Explicit ranges
+		{"month first range", "any shows from dec 5 to dec 10?", wednesday, "2026-12-05", "2026-12-10", true},
+		{"day first range", "5th dec - 10th dec", wednesday, "2026-12-05", "2026-12-10", true},
+		{"range over new year", "dec 28 to jan 3", wednesday, "2026-12-28", "2027-01-03", true},
+		{"range with year", "5th of dec 2027 till 7th of dec 2027", wednesday, "2027-12-05", "2027-12-07", true},
+		{"shared month range", "5th to 10th december", wednesday, "2026-12-05", "2026-12-10", true},
+		{"shared month range month first", "December 5 - 10", wednesday, "2026-12-05", "2026-12-10", true},
+		{"numeric range", "5/12 to 10/12", wednesday, "2026-12-05", "2026-12-10", true},
+		{"inverted range falls back to a single date", "10th to 5th december", wednesday, "2026-12-05", "2026-12-05", true},
+
+		// Single dates
+		{"iso date", "2026-12-05", wednesday, "2026-12-05", "2026-12-05", true},
+		{"numeric date with year", "05/12/2027", wednesday, "2027-12-05", "2027-12-05", true},
+		{"numeric date after cue", "anything on 5/12?", wednesday, "2026-12-05", "2026-12-05", true},
+		{"numeric date alone", "5/12", wednesday, "2026-12-05", "2026-12-05", true},
+		{"month first date", "December 5th", wednesday, "2026-12-05", "2026-12-05", true},
+		{"month first date with year", "dec 5, 2027", wednesday, "2027-12-05", "2027-12-05", true},
+		{"day first date", "5th of dec", wednesday, "2026-12-05", "2026-12-05", true},
+		{"date today", "oct 14", wednesday, "2026-10-14", "2026-10-14", true},
+		{"date rolls over to next year", "March 3rd", wednesday, "2027-03-03", "2027-03-03", true},
+		{"past date this month rolls over", "13th october", wednesday, "2027-10-13", "2027-10-13", true},
+
+		// Invalid dates
+		{"february 30th", "feb 30", wednesday, "", "", false},
+		{"february 30th day first", "30th of february", wednesday, "", "", false},
+		{"invalid iso date", "2026-02-30", wednesday, "", "", false},
+		{"invalid numeric date", "on 31/4", wednesday, "", "", false},
+		{"invalid month", "on 5/13", wednesday, "", "", false},
+		{"leap day", "29th feb 2028", wednesday, "2028-02-29", "2028-02-29", true},
+		{"leap day in common year", "29th feb 2027", wednesday, "", "", false},
+
+		// Numbers that are not dates
+		{"round the clock", "24/7 party", wednesday, "", "", false},
+		{"round the clock in text", "any 24/7 lounge open", wednesday, "", "", false},
+		{"fraction", "1/2 price tickets", wednesday, "", "", false},
+
+		// Holidays and seasons
+		{"christmas eve", "christmas eve", wednesday, "2026-12-24", "2026-12-24", true},
+		{"christmas", "xmas concerts", wednesday, "2026-12-25", "2026-12-25", true},
+		{"boxing day", "boxing day", wednesday, "2026-12-26", "2026-12-26", true},
+		{"new year's eve", "new year’s eve", wednesday, "2026-12-31", "2026-12-31", true},
+		{"crossover", "where can I cross over, any crossover service?", wednesday, "2026-12-31", "2026-12-31", true},
+		{"crossover night", "crossover night", wednesday, "2026-12-31", "2026-12-31", true},
+		{"new year's day", "new year's day", wednesday, "2027-01-01", "2027-01-01", true},
+		{"detty december", "detty december plans", wednesday, "2026-12-01", "2026-12-31", true},
+		{"detty december during december", "detty dec", lateDec, "2026-12-30", "2026-12-31", true},
+		{"yuletide", "yuletide", wednesday, "2026-12-20", "2026-12-31", true},
+		{"ember months", "ember months", wednesday, "2026-10-14", "2026-12-31", true},
+		{"christmas after christmas", "christmas", lateDec, "2027-12-25", "2027-12-25", true},
+
+		// Relative days
+		{"next tomorrow", "next tomorrow", wednesday, "2026-10-16", "2026-10-16", true},
+		{"day after tomorrow", "day after tomorrow", wednesday, "2026-10-16", "2026-10-16", true},
+		{"tomorrow", "tmrw", wednesday, "2026-10-15", "2026-10-15", true},
+		{"tomorrow over new year", "2moro", lateDec, "2026-12-31", "2026-12-31", true},
+		{"tonight", "anything tonight?", wednesday, "2026-10-14", "2026-10-14", true},
+
+		// Weekends
+		{"this weekend", "this weekend", wednesday, "2026-10-16", "2026-10-18", true},
+		{"weekend wey dey come", "wetin dey happen weekend wey dey come", wednesday, "2026-10-16", "2026-10-18", true},
+		{"next weekend", "next weekend", wednesday, "2026-10-23", "2026-10-25", true},
+		{"weekend after this", "the weekend after this", wednesday, "2026-10-23", "2026-10-25", true},
+		{"weekend after next", "the weekend after next", wednesday, "2026-10-30", "2026-11-01", true},
+		{"weekend on saturday", "this weekend", saturday, "2026-10-17", "2026-10-18", true},
+		{"weekend on sunday", "weekend", sunday, "2026-10-18", "2026-10-18", true},
+		{"next weekend on saturday", "next weekend", saturday, "2026-10-23", "2026-10-25", true},
+		{"next weekend on sunday", "next weekend", sunday, "2026-10-23", "2026-10-25", true},
+		{"weekend after next on sunday", "weekend after next", sunday, "2026-10-30", "2026-11-01", true},
+
+		// Weeks
+		{"next two weeks", "next 2 weeks", wednesday, "2026-10-14", "2026-10-28", true},
+		{"coming weeks in words", "coming three weeks", wednesday, "2026-10-14", "2026-11-04", true},
+		{"next week", "next week", wednesday, "2026-10-19", "2026-10-25", true},
+		{"next week on sunday", "next week", sunday, "2026-10-19", "2026-10-25", true},
+		{"this week", "this week", wednesday, "2026-10-14", "2026-10-18", true},
+		{"in two weeks", "in two weeks", wednesday, "2026-10-28", "2026-10-28", true},
+		{"week from now", "a week from now", wednesday, "2026-10-21", "2026-10-21", true},
+		{"in days", "in 3 days", wednesday, "2026-10-17", "2026-10-17", true},
+
+		// Months
+		{"next month", "next month", wednesday, "2026-11-01", "2026-11-30", true},
+		{"next month in december", "next month", lateDec, "2027-01-01", "2027-01-31", true},
+		{"this month", "this month", wednesday, "2026-10-14", "2026-10-31", true},
+		{"month end", "month end", wednesday, "2026-10-25", "2026-10-31", true},
+		{"month end in last week", "end of the month", lateDec, "2026-12-30", "2026-12-31", true},
+		{"whole month", "anything in december", wednesday, "2026-12-01", "2026-12-31", true},
+		{"whole month next year", "in march", wednesday, "2027-03-01", "2027-03-31", true},
+		{"whole month in may", "anything happening in may?", wednesday, "2027-05-01", "2027-05-31", true},
+		{"month ending the message", "any concerts for december?", wednesday, "2026-12-01", "2026-12-31", true},
+		{"month before event word", "this december shows in lagos", wednesday, "2026-12-01", "2026-12-31", true},
+		{"around month", "what's on around march", wednesday, "2027-03-01", "2027-03-31", true},
+
+		// Months that are not dates
+		{"modal may after this", "this may sound odd but do you sell tickets", wednesday, "", "", false},
+		{"modal may after around", "around may I ask how to pay", wednesday, "", "", false},
+		{"modal may after in", "in may I ask something", wednesday, "", "", false},
+		{"march madness", "any tickets for march madness", wednesday, "", "", false},
+
+		// Weekdays
+		{"weekday", "friday", wednesday, "2026-10-16", "2026-10-16", true},
+		{"weekday today", "wednesday", wednesday, "2026-10-14", "2026-10-14", true},
+		{"next weekday", "next friday", wednesday, "2026-10-23", "2026-10-23", true},
+		{"next monday on sunday", "next monday", sunday, "2026-10-19", "2026-10-19", true},
+
+		// No date
+		{"no date", "show me afrobeats concerts in lagos", wednesday, "", "", false},
+		{"empty", "", wednesday, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse(tt.text, tt.now)
+			if ok != tt.ok {
+				t.Fatalf("Parse(%q) ok = %v, want %v (got %s to %s)", tt.text, ok, tt.ok, got.StartDate(), got.EndDate())
+			}
+
+			if !ok {
+				return
+			}
+
+			if got.StartDate() != tt.start || got.EndDate() != tt.end {
+				t.Errorf("Parse(%q) = %s to %s, want %s to %s", tt.text, got.StartDate(), got.EndDate(), tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseKeepsLocation(t *testing.T) {
+	lagos := time.FixedZone("WAT", 60*60)
+
+	// 11:30pm UTC on the 14th is already the 15th in Lagos
+	got, ok := Parse("today", time.Date(2026, time.October, 14, 23, 30, 0, 0, time.UTC).In(lagos))
+	if !ok {
+		t.Fatal("Parse(\"today\") returned no date")
+	}
+
+	if got.StartDate() != "2026-10-15" || got.Start.Location() != lagos {
+		t.Errorf("Parse(\"today\") = %s in %s, want 2026-10-15 in WAT", got.StartDate(), got.Start.Location())
+	}
+}
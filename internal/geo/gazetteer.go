@@ -0,0 +1,149 @@
+package geo
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrPlaceNotFound is returned when a place name cannot be resolved to coordinates
+var ErrPlaceNotFound = errors.New("place not found")
+
+type Place struct {
+	Point
+	Name  string `json:"name"`
+	City  string `json:"city"`
+	State string `json:"state"`
+}
+
+// Label describes the place by its name and the city it is in, e.g. "Lekki, Lagos"
+func (p Place) Label() string {
+	if p.City == "" || p.City == p.Name {
+		return p.Name
+	}
+
+	return p.Name + ", " + p.City
+}
+
+// Geocoder turns a place name typed by the user into coordinates
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (*Place, error)
+}
+
+//go:embed data/nigeria.csv
+var gazetteerData embed.FS
+
+// Gazetteer is an offline geocoder backed by a list of Nigerian cities and neighbourhoods
+type Gazetteer struct {
+	places []Place
+	names  map[string]int // Normalized names and aliases, mapped to their index in places
+}
+
+func NewGazetteer() (*Gazetteer, error) {
+	file, err := gazetteerData.Open("data/nigeria.csv")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening gazetteer data: %s", err.Error())
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading gazetteer data: %s", err.Error())
+	}
+
+	g := &Gazetteer{names: map[string]int{}}
+
+	// Skip the header row
+	for i, record := range records[1:] {
+		if len(record) != 6 {
+			return nil, fmt.Errorf("Error parsing gazetteer data: Invalid number of columns on line %d", i+2)
+		}
+
+		lat, latErr := strconv.ParseFloat(record[3], 64)
+		lng, lngErr := strconv.ParseFloat(record[4], 64)
+		if latErr != nil || lngErr != nil {
+			return nil, fmt.Errorf("Error parsing gazetteer data: Invalid coordinates on line %d", i+2)
+		}
+
+		g.places = append(g.places, Place{
+			Point: Point{Latitude: lat, Longitude: lng},
+			Name:  record[0],
+			City:  record[1],
+			State: record[2],
+		})
+
+		names := []string{record[0]}
+		if record[5] != "" {
+			names = append(names, strings.Split(record[5], "|")...)
+		}
+
+		for _, name := range names {
+			key := normalizePlace(name)
+			if _, exists := g.names[key]; exists {
+				return nil, fmt.Errorf("Error parsing gazetteer data: Duplicate place name %q on line %d", name, i+2)
+			}
+
+			g.names[key] = len(g.places) - 1
+		}
+	}
+
+	return g, nil
+}
+
+// Geocode resolves a place name, ignoring case and punctuation. If the query does not exactly match a
+// known place, the longest place name found in it is used, so "Lekki, Lagos" resolves to Lekki.
+func (g *Gazetteer) Geocode(ctx context.Context, query string) (*Place, error) {
+	key := normalizePlace(query)
+	if key == "" {
+		return nil, ErrPlaceNotFound
+	}
+
+	if i, ok := g.names[key]; ok {
+		place := g.places[i]
+		return &place, nil
+	}
+
+	best, bestLen := -1, 0
+	padded := " " + key + " "
+	for name, i := range g.names {
+		if !strings.Contains(padded, " "+name+" ") {
+			continue
+		}
+
+		// Prefer longer names, then neighbourhoods over the city they are in, then the first listed place
+		if len(name) > bestLen || (len(name) == bestLen && g.ranksBefore(i, best)) {
+			best, bestLen = i, len(name)
+		}
+	}
+
+	if best < 0 {
+		return nil, ErrPlaceNotFound
+	}
+
+	place := g.places[best]
+	return &place, nil
+}
+
+func (g *Gazetteer) ranksBefore(i, j int) bool {
+	iCity := g.places[i].Name == g.places[i].City
+	jCity := g.places[j].Name == g.places[j].City
+	if iCity != jCity {
+		return !iCity
+	}
+
+	return i < j
+}
+
+// normalizePlace lowercases a place name and replaces punctuation with single spaces
+func normalizePlace(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(fields, " ")
+}
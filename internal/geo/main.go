@@ -0,0 +1,37 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+const earthRadiusKm = 6371.0
+
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Distance returns the great-circle distance between two points in kilometres, using the haversine formula
+func Distance(a, b Point) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// FormatDistance formats a distance for display, e.g. "850 m" or "4.2 km"
+func FormatDistance(km float64) string {
+	switch {
+	case km < 1:
+		return fmt.Sprintf("%d m", int(math.Round(km*1000/10))*10)
+	case km < 100:
+		return fmt.Sprintf("%.1f km", km)
+	default:
+		return fmt.Sprintf("%d km", int(math.Round(km)))
+	}
+}
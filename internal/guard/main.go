@@ -0,0 +1,49 @@
+package guard
+
+import "github.com/xerdin442/ticketing-bot/internal/llm"
+
+type Category string
+
+const (
+	Allowed   Category = "ALLOWED"
+	OffTopic  Category = "OFF_TOPIC"
+	Abusive   Category = "ABUSIVE"
+	Injection Category = "INJECTION"
+)
+
+// Verdict is the classification of a message from the user
+type Verdict struct {
+	Category Category
+	Reason   string
+}
+
+func (v Verdict) Allowed() bool {
+	return v.Category == Allowed
+}
+
+// Reply returns the message sent to the user in place of a model response when the input is blocked
+func (v Verdict) Reply() string {
+	switch v.Category {
+	case Injection:
+		return "Sorry, I can't do that. I can only help you find events and buy tickets."
+	case Abusive:
+		return "Let's keep things respectful. I'm here to help you find events and buy tickets whenever you're ready."
+	case OffTopic:
+		return "I can only help with finding events and buying tickets. What kind of event are you looking for?"
+	default:
+		return ""
+	}
+}
+
+// Guard screens messages from the user before they reach the model, and replies
+// from the model before they reach the user.
+type Guard struct {
+	model      llm.LLM
+	modelCheck bool
+}
+
+// NewGuard returns a guard that classifies input with heuristics. If modelCheck is set, input that
+// passes the heuristics is also classified by the model.
+func NewGuard(model llm.LLM, modelCheck bool) *Guard {
+	return &Guard{model: model, modelCheck: modelCheck}
+}
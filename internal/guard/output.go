@@ -0,0 +1,348 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+)
+
+// Model text may quote a total for several tickets of the same tier
+const maxQuotedQuantity = 10
+
+var (
+	// "₦25,000", "NGN 25000", "N25,000.00", "$25.00", "KWD 1.234", "€1.234,50"
+	pricePrefixPattern = regexp.MustCompile(`(?:` + currencyPrefixes() + `)\s?` + amountPattern)
+	// "25,000 naira", "25000 NGN", "25 €"
+	priceSuffixPattern = regexp.MustCompile(`\b` + amountPattern + `\s?(?:` + currencySuffixes() + `)`)
+	// "event ID: 12", "ID 12", "#12"
+	idPattern = regexp.MustCompile(`(?i)(?:\bid\s*[:#]?\s*|#)(\d+)\b`)
+	// Capitalized names followed by "tier" or "ticket", e.g. "VIP tier" or "Early Bird tickets"
+	tierPattern = regexp.MustCompile(`\b((?:[A-Z][\w&+'-]*\s+){1,4})(?i:tier|tiers|ticket|tickets)\b`)
+	// Bold or quoted spans, which is how the model presents event titles
+	titlePattern = regexp.MustCompile(`\*([^*\n]{3,80})\*|"([^"\n]{3,80})"|“([^”\n]{3,80})”`)
+	// "December 5", "Dec 5th, 2026", "5th of December"
+	monthDayPattern = regexp.MustCompile(`(?i)\b` + monthNames + `\.?\s+(\d{1,2})(?:st|nd|rd|th)?\b`)
+	dayMonthPattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthNames + `\b`)
+)
+
+// Amounts with thousand separators, and up to three decimal places after a point or comma
+const amountPattern = `(\d{1,3}(?:[,. \x{00a0}]\d{3})+(?:[.,]\d{1,3})?|\d+(?:[.,]\d{1,3})?)`
+
+// currencyPrefixes matches the codes and symbols written before an amount, including N for naira
+func currencyPrefixes() string {
+	codes, symbols := dto.CurrencyMarkers()
+
+	markers := make([]string, 0, len(codes)+len(symbols)+1)
+	for _, code := range append(codes, "N") {
+		markers = append(markers, `\b`+code)
+	}
+	for _, symbol := range symbols {
+		if unicode.IsLetter([]rune(symbol)[0]) {
+			markers = append(markers, `\b`+regexp.QuoteMeta(symbol))
+		} else {
+			markers = append(markers, regexp.QuoteMeta(symbol))
+		}
+	}
+
+	// Longer markers first, so "GH₵" is not matched as a shorter marker
+	sort.SliceStable(markers, func(i, j int) bool { return len(markers[i]) > len(markers[j]) })
+
+	return strings.Join(markers, "|")
+}
+
+// currencySuffixes matches the codes and symbols written after an amount, including the word naira
+func currencySuffixes() string {
+	codes, symbols := dto.CurrencyMarkers()
+
+	quoted := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		// Symbols that are letters, such as R for rand, are only written before an amount
+		if strings.ContainsFunc(symbol, func(r rune) bool { return !unicode.IsLetter(r) }) {
+			quoted = append(quoted, regexp.QuoteMeta(symbol))
+		}
+	}
+
+	return strings.Join(quoted, "|") + `|(?i:\b(?:naira|` + strings.Join(codes, "|") + `)\b)`
+}
+
+const monthNames = `(jan|january|feb|february|mar|march|apr|april|may|jun|june|jul|july|aug|august|sep|sept|september|oct|october|nov|november|dec|december)`
+
+// Fields of a function response that hold the price of a ticket or the total of an order
+var priceFields = map[string]bool{"price": true, "discountPrice": true, "unitPrice": true, "total": true}
+
+// Words that may appear in a title in lower case
+var titleConnectors = map[string]bool{
+	"the": true, "of": true, "and": true, "at": true, "in": true, "on": true, "a": true, "an": true,
+	"for": true, "with": true, "&": true, "-": true, "x": true, "vs": true,
+}
+
+// Words that can precede "tier" or "ticket" without being the name of a tier
+var tierStopwords = map[string]bool{
+	"the": true, "a": true, "an": true, "your": true, "my": true, "our": true, "this": true,
+	"that": true, "these": true, "those": true, "each": true, "per": true, "all": true, "any": true,
+	"available": true, "selected": true, "chosen": true, "event": true, "one": true, "two": true,
+	"three": true, "four": true, "five": true, "which": true, "what": true, "buy": true, "book": true,
+	"get": true, "price": true, "prices": true, "discount": true, "discounted": true, "cheapest": true,
+	"more": true, "some": true, "no": true, "few": true, "limited": true, "many": true, "other": true,
+	"both": true, "only": true, "remaining": true, "sold": true, "great": true,
+}
+
+// facts are the values in a function response that the model may quote
+type facts struct {
+	ids    map[int64]bool
+	prices []float64       // Values of the price fields only, so IDs and counts are not taken for prices
+	names  map[string]bool // Words in the names, titles and venues of the response
+	dates  map[string]bool // Calendar days in the response, formatted as "01-02"
+}
+
+// CheckOutput returns the event IDs, titles, dates, tier names and prices in the model's text that do not
+// appear in the given data, usually the last function response and the arguments of its call.
+// No data means nothing may be quoted.
+func (g *Guard) CheckOutput(text string, data ...map[string]any) []string {
+	f := collectFacts(data...)
+	var violations []string
+
+	for _, price := range extractPrices(text) {
+		if !f.hasPrice(price.values...) {
+			violations = append(violations, fmt.Sprintf("price %s", price.text))
+		}
+	}
+
+	for _, m := range idPattern.FindAllStringSubmatch(text, -1) {
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		if !f.ids[id] {
+			violations = append(violations, fmt.Sprintf("ID %d", id))
+		}
+	}
+
+	plain := strings.NewReplacer("*", "", "_", "", "~", "").Replace(text)
+	for _, m := range tierPattern.FindAllStringSubmatch(plain, -1) {
+		name := tierName(m[1])
+		if name != "" && !f.hasName(name) {
+			violations = append(violations, fmt.Sprintf("tier %q", name))
+		}
+	}
+
+	for _, m := range titlePattern.FindAllStringSubmatch(text, -1) {
+		title := strings.TrimSpace(m[1] + m[2] + m[3])
+		if isTitle(title) && !f.hasName(title) {
+			violations = append(violations, fmt.Sprintf("title %q", title))
+		}
+	}
+
+	for _, date := range extractDates(text) {
+		if !f.dates[date] {
+			violations = append(violations, fmt.Sprintf("date %s", date))
+		}
+	}
+
+	return violations
+}
+
+// isTitle reports whether a span is written in title case, e.g. "Afro Nation Lagos" but not "Note"
+func isTitle(span string) bool {
+	words := strings.Fields(span)
+	if len(words) < 2 || len(words) > 10 || strings.ContainsAny(span, "₦:") {
+		return false
+	}
+
+	for _, word := range words {
+		r := []rune(word)[0]
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && !titleConnectors[strings.ToLower(word)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func extractDates(text string) []string {
+	var dates []string
+
+	for _, m := range monthDayPattern.FindAllStringSubmatch(text, -1) {
+		dates = append(dates, dateKey(m[1], m[2]))
+	}
+
+	for _, m := range dayMonthPattern.FindAllStringSubmatch(text, -1) {
+		dates = append(dates, dateKey(m[2], m[1]))
+	}
+
+	return dates
+}
+
+func dateKey(month, day string) string {
+	t, err := time.Parse("Jan 2", strings.ToUpper(month[:1])+strings.ToLower(month[1:3])+" "+day)
+	if err != nil {
+		return month + " " + day
+	}
+
+	return t.Format("01-02")
+}
+
+// quotedPrice is a price in the model's text with the values it can be read as, since a point
+// or comma may separate either thousands or decimals depending on the currency
+type quotedPrice struct {
+	text   string
+	values []float64
+}
+
+func extractPrices(text string) []quotedPrice {
+	var prices []quotedPrice
+
+	for _, re := range []*regexp.Regexp{pricePrefixPattern, priceSuffixPattern} {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			price := quotedPrice{text: strings.TrimSpace(m[0]), values: readAmount(m[1])}
+			if len(price.values) > 0 {
+				prices = append(prices, price)
+			}
+		}
+	}
+
+	return prices
+}
+
+// readAmount parses an amount with a decimal point, e.g. "25,000.50", and with a decimal comma, e.g.
+// "1.234,50". An amount that uses both separators is only read the way its last separator allows.
+func readAmount(amount string) []float64 {
+	amount = strings.Join(strings.Fields(amount), "")
+	comma, point := strings.LastIndex(amount, ","), strings.LastIndex(amount, ".")
+
+	var readings []*strings.Replacer
+	if comma == -1 || point == -1 || point > comma {
+		readings = append(readings, strings.NewReplacer(",", ""))
+	}
+	if comma == -1 || point == -1 || comma > point {
+		readings = append(readings, strings.NewReplacer(".", "", ",", "."))
+	}
+
+	var values []float64
+	for _, replacer := range readings {
+		if value, err := strconv.ParseFloat(replacer.Replace(amount), 64); err == nil {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// tierName strips the words that precede a tier name, e.g. "The VIP" becomes "VIP"
+func tierName(candidate string) string {
+	words := strings.Fields(candidate)
+	for len(words) > 0 && tierStopwords[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+func collectFacts(data ...map[string]any) *facts {
+	f := &facts{ids: map[int64]bool{}, names: map[string]bool{}, dates: map[string]bool{}}
+
+	// The model may always mention the current date
+	f.addDate(time.Now())
+
+	for _, d := range data {
+		if d == nil {
+			continue
+		}
+
+		// Responses are normalized through JSON since they hold typed structs when fresh
+		// from the backend service, and generic maps when read from the chat history
+		var normalized any
+		raw, err := json.Marshal(d)
+		if err != nil || json.Unmarshal(raw, &normalized) != nil {
+			continue
+		}
+
+		f.walk("", normalized, false)
+	}
+
+	return f
+}
+
+// addDate records the calendar day of a time in UTC and in the default timezone of users
+func (f *facts) addDate(t time.Time) {
+	loc, err := time.LoadLocation(util.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	f.dates[t.UTC().Format("01-02")] = true
+	f.dates[t.In(loc).Format("01-02")] = true
+}
+
+// walk records the facts in a value. The price flag is set within price fields, which hold
+// either a number or an amount object, e.g. {"amount": 25000, "currency": "NGN"}.
+func (f *facts) walk(key string, value any, price bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			f.walk(k, item, price || priceFields[k])
+		}
+	case []any:
+		for _, item := range v {
+			f.walk(key, item, price)
+		}
+	case float64:
+		if price {
+			f.prices = append(f.prices, v)
+		}
+		if key == "id" || key == "eventId" || key == "tierId" {
+			f.ids[int64(v)] = true
+		}
+	case string:
+		switch key {
+		case "name", "title", "tierName", "tier", "venue", "address", "message":
+			for _, word := range strings.Fields(v) {
+				f.names[normalizeWord(word)] = true
+			}
+		}
+
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.addDate(t)
+		} else if t, err := time.Parse("2006-01-02", v); err == nil {
+			f.dates[t.Format("01-02")] = true
+		}
+	}
+}
+
+// hasPrice reports whether any of the values is a price in the response, or a total of several tickets at that price
+func (f *facts) hasPrice(values ...float64) bool {
+	for _, price := range values {
+		for _, n := range f.prices {
+			for q := 1; q <= maxQuotedQuantity; q++ {
+				if math.Abs(n*float64(q)-price) < 0.0005 {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// hasName reports whether every word of the name appears in the response, which allows
+// names that combine the title of an event with the name of a tier
+func (f *facts) hasName(name string) bool {
+	for _, word := range strings.Fields(name) {
+		word = normalizeWord(word)
+		if word != "" && !f.names[word] && !titleConnectors[word] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,!?;:'\"()"))
+}
@@ -0,0 +1,119 @@
+package guard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+)
+
+func TestCheckOutput(t *testing.T) {
+	eventResponse := map[string]any{
+		"events": []map[string]any{
+			{"id": 12, "title": "Afro Nation Lagos", "venue": "Eko Atlantic", "date": "2026-12-05T18:00:00Z", "remainingTickets": 50},
+		},
+		"tickets": []map[string]any{
+			{"name": "VIP", "price": dto.NewMoney(25000, "NGN")},
+			{"name": "Early Bird", "price": 15000},
+		},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		data []map[string]any
+		want []string
+	}{
+		{
+			name: "correct text",
+			text: "*Afro Nation Lagos* (ID 12) holds on December 5 at Eko Atlantic. VIP tickets cost ₦25,000.00, so 2 VIP tickets are ₦50,000. Early Bird tickets are 15000 naira.",
+			data: []map[string]any{eventResponse},
+		},
+		{
+			name: "wrong price",
+			text: "VIP tickets cost ₦20,000.",
+			data: []map[string]any{eventResponse},
+			want: []string{"price ₦20,000"},
+		},
+		{
+			name: "count quoted as price",
+			text: "Tickets start at NGN 50",
+			data: []map[string]any{eventResponse},
+			want: []string{"price NGN 50"},
+		},
+		{
+			name: "invented ID",
+			text: "Reply with event ID: 99 to select it.",
+			data: []map[string]any{eventResponse},
+			want: []string{"ID 99"},
+		},
+		{
+			name: "invented tier",
+			text: "The Platinum tier is still available.",
+			data: []map[string]any{eventResponse},
+			want: []string{`tier "Platinum"`},
+		},
+		{
+			name: "invented title",
+			text: "You might also like *Detty December Fest*.",
+			data: []map[string]any{eventResponse},
+			want: []string{`title "Detty December Fest"`},
+		},
+		{
+			name: "wrong date",
+			text: "It holds on December 6.",
+			data: []map[string]any{eventResponse},
+			want: []string{"date 12-06"},
+		},
+		{
+			name: "total of several tickets",
+			text: "3 Early Bird tickets cost ₦45,000 in total.",
+			data: []map[string]any{eventResponse},
+		},
+		{
+			name: "three decimal currency",
+			text: "Regular tickets are KWD 1.234 each, KWD 2.468 for two.",
+			data: []map[string]any{{"tickets": []map[string]any{{"name": "Regular", "price": dto.NewMoney(1.234, "KWD")}}}},
+		},
+		{
+			name: "wrong three decimal price",
+			text: "Regular tickets are KWD 1.500 each.",
+			data: []map[string]any{{"tickets": []map[string]any{{"name": "Regular", "price": dto.NewMoney(1.234, "KWD")}}}},
+			want: []string{"price KWD 1.500"},
+		},
+		{
+			name: "dollar and euro prices",
+			text: "Tickets are $25.00 each, or €1.234,50 for a table.",
+			data: []map[string]any{{"unitPrice": dto.NewMoney(25, "USD"), "total": dto.NewMoney(1234.5, "EUR")}},
+		},
+		{
+			name: "wrong dollar price",
+			text: "Tickets are $30 each.",
+			data: []map[string]any{{"unitPrice": dto.NewMoney(25, "USD")}},
+			want: []string{"price $30"},
+		},
+		{
+			name: "no data",
+			text: "VIP costs ₦5,000.",
+			want: []string{"price ₦5,000"},
+		},
+		{
+			name: "text without facts",
+			text: "Which event would you like tickets for?",
+		},
+	}
+
+	g := NewGuard(nil, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.CheckOutput(tt.text, tt.data...)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckOutput(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
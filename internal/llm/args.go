@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ArgumentError describes an argument of a tool call that is missing or does not match its schema
+type ArgumentError struct {
+	Field   string
+	Message string
+	Missing bool
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("Invalid argument %q: %s", e.Field, e.Message)
+}
+
+// DecodeArgs validates the arguments of a tool call against the schema of its parameters and decodes
+// them into out, which must be a pointer to a struct. If out has a Validate method, it is called last.
+func DecodeArgs(schema *Schema, args map[string]any, out any) error {
+	if schema != nil {
+		if err := schema.validateArgs(args); err != nil {
+			return err
+		}
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("Error encoding tool call arguments: %s", err.Error())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &ArgumentError{Field: typeErr.Field, Message: "must be a " + describeKind(typeErr.Type)}
+		}
+
+		return &ArgumentError{Field: "", Message: err.Error()}
+	}
+
+	if v, ok := out.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
+
+func (s *Schema) validateArgs(args map[string]any) error {
+	for _, name := range s.Required {
+		if value, ok := args[name]; !ok || value == nil || value == "" {
+			return &ArgumentError{Field: name, Message: "is required", Missing: true}
+		}
+	}
+
+	for name, value := range args {
+		property, ok := s.Properties[name]
+		if !ok {
+			return &ArgumentError{Field: name, Message: "is not a parameter of this function"}
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if err := property.validateValue(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateValue(field string, value any) error {
+	switch s.Type {
+	case TypeString:
+		str, ok := value.(string)
+		if !ok {
+			return &ArgumentError{Field: field, Message: "must be a string"}
+		}
+
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return &ArgumentError{Field: field, Message: "must be one of " + strings.Join(s.Enum, ", ")}
+		}
+	case TypeNumber, TypeInteger:
+		number, ok := value.(float64)
+		if !ok {
+			return &ArgumentError{Field: field, Message: "must be a number"}
+		}
+
+		if s.Type == TypeInteger && number != math.Trunc(number) {
+			return &ArgumentError{Field: field, Message: "must be a whole number"}
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return &ArgumentError{Field: field, Message: "must be true or false"}
+		}
+	case TypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			return &ArgumentError{Field: field, Message: "must be a list"}
+		}
+
+		if s.Items != nil {
+			for _, item := range items {
+				if err := s.Items.validateValue(field, item); err != nil {
+					return err
+				}
+			}
+		}
+	case TypeObject:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return &ArgumentError{Field: field, Message: "must be an object"}
+		}
+
+		if s.Properties != nil {
+			return s.validateArgs(object)
+		}
+	}
+
+	return nil
+}
+
+// CheckArgsType reports any difference between the parameters declared by a schema and
+// the fields of the struct its arguments are decoded into
+func (s *Schema) CheckArgsType(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+
+	var problems []string
+	for name, property := range s.Properties {
+		fieldType, ok := fields[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("parameter %q has no struct field", name))
+			continue
+		}
+
+		if !property.matchesKind(fieldType) {
+			problems = append(problems, fmt.Sprintf("parameter %q of type %s cannot be decoded into %s", name, property.Type, fieldType))
+		}
+	}
+
+	for name := range fields {
+		if _, ok := s.Properties[name]; !ok {
+			problems = append(problems, fmt.Sprintf("struct field %q is not a declared parameter", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func (s *Schema) matchesKind(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch s.Type {
+	case TypeString:
+		return t.Kind() == reflect.String
+	case TypeNumber:
+		return t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64
+	case TypeInteger:
+		return t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64
+	case TypeBoolean:
+		return t.Kind() == reflect.Bool
+	case TypeArray:
+		return t.Kind() == reflect.Slice && (s.Items == nil || s.Items.matchesKind(t.Elem()))
+	case TypeObject:
+		return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	default:
+		return false
+	}
+}
+
+func describeKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	default:
+		return t.Kind().String()
+	}
+}
@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type purchaseArgs struct {
+	EventID  int      `json:"eventId"`
+	TierName string   `json:"tierName"`
+	Quantity int      `json:"quantity,omitempty"`
+	Budget   float64  `json:"budget,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Notify   bool     `json:"notify,omitempty"`
+}
+
+func (a *purchaseArgs) Validate() error {
+	if a.Quantity < 0 {
+		return errors.New("Quantity cannot be negative")
+	}
+
+	return nil
+}
+
+var purchaseSchema = &Schema{
+	Type: TypeObject,
+	Properties: map[string]*Schema{
+		"eventId":  {Type: TypeInteger},
+		"tierName": {Type: TypeString, Enum: []string{"Regular", "VIP"}},
+		"quantity": {Type: TypeInteger},
+		"budget":   {Type: TypeNumber},
+		"tags":     {Type: TypeArray, Items: &Schema{Type: TypeString}},
+		"notify":   {Type: TypeBoolean},
+	},
+	Required: []string{"eventId", "tierName"},
+}
+
+func TestDecodeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  *Schema
+		args    map[string]any
+		want    purchaseArgs
+		field   string // Field of the expected ArgumentError, if any
+		missing bool
+		errText string // Part of the expected error message
+	}{
+		{
+			name:   "valid arguments",
+			schema: purchaseSchema,
+			args:   map[string]any{"eventId": 12.0, "tierName": "VIP", "quantity": 2.0, "budget": 150.5, "tags": []any{"music"}, "notify": true},
+			want:   purchaseArgs{EventID: 12, TierName: "VIP", Quantity: 2, Budget: 150.5, Tags: []string{"music"}, Notify: true},
+		},
+		{
+			name:   "null optional argument",
+			schema: purchaseSchema,
+			args:   map[string]any{"eventId": 12.0, "tierName": "Regular", "quantity": nil},
+			want:   purchaseArgs{EventID: 12, TierName: "Regular"},
+		},
+		{
+			name:    "missing required argument",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0},
+			field:   "tierName",
+			missing: true,
+			errText: "is required",
+		},
+		{
+			name:    "empty required argument",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": ""},
+			field:   "tierName",
+			missing: true,
+		},
+		{
+			name:    "null required argument",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": nil, "tierName": "VIP"},
+			field:   "eventId",
+			missing: true,
+		},
+		{
+			name:    "no arguments",
+			schema:  purchaseSchema,
+			args:    nil,
+			field:   "eventId",
+			missing: true,
+		},
+		{
+			name:    "float for integer",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "quantity": 2.5},
+			field:   "quantity",
+			errText: "must be a whole number",
+		},
+		{
+			name:    "string for integer",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": "12", "tierName": "VIP"},
+			field:   "eventId",
+			errText: "must be a number",
+		},
+		{
+			name:    "value outside enum",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "Gold"},
+			field:   "tierName",
+			errText: "must be one of Regular, VIP",
+		},
+		{
+			name:    "wrong type in list",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "tags": []any{"music", 3.0}},
+			field:   "tags",
+			errText: "must be a string",
+		},
+		{
+			name:    "string for list",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "tags": "music"},
+			field:   "tags",
+			errText: "must be a list",
+		},
+		{
+			name:    "string for boolean",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "notify": "yes"},
+			field:   "notify",
+			errText: "must be true or false",
+		},
+		{
+			name:    "undeclared argument",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "seat": "A1"},
+			field:   "seat",
+			errText: "is not a parameter of this function",
+		},
+		{
+			name:    "rejected by Validate",
+			schema:  purchaseSchema,
+			args:    map[string]any{"eventId": 12.0, "tierName": "VIP", "quantity": -1.0},
+			errText: "Quantity cannot be negative",
+		},
+
+		// Without a schema, the arguments are only checked by decoding them into the struct
+		{
+			name:    "float for integer without schema",
+			args:    map[string]any{"eventId": 12.0, "quantity": 2.5},
+			field:   "quantity",
+			errText: "must be a whole number",
+		},
+		{
+			name:    "object for string without schema",
+			args:    map[string]any{"tierName": map[string]any{"name": "VIP"}},
+			field:   "tierName",
+			errText: "must be a string",
+		},
+		{
+			name:    "unknown field without schema",
+			args:    map[string]any{"eventId": 12.0, "seat": "A1"},
+			errText: "unknown field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got purchaseArgs
+			err := DecodeArgs(tt.schema, tt.args, &got)
+
+			if tt.errText == "" && tt.field == "" {
+				if err != nil {
+					t.Fatalf("DecodeArgs() returned error: %s", err.Error())
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("DecodeArgs() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("DecodeArgs() returned no error, decoded %+v", got)
+			}
+
+			if tt.errText != "" && !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("DecodeArgs() error = %q, want it to contain %q", err.Error(), tt.errText)
+			}
+
+			var argErr *ArgumentError
+			if tt.field != "" {
+				if !errors.As(err, &argErr) {
+					t.Fatalf("DecodeArgs() error = %T, want *ArgumentError", err)
+				}
+
+				if argErr.Field != tt.field || argErr.Missing != tt.missing {
+					t.Errorf("DecodeArgs() error field = %q (missing %v), want %q (missing %v)", argErr.Field, argErr.Missing, tt.field, tt.missing)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateArgsNestedObject(t *testing.T) {
+	schema := &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"location": {
+				Type: TypeObject,
+				Properties: map[string]*Schema{
+					"latitude":  {Type: TypeNumber},
+					"longitude": {Type: TypeNumber},
+				},
+				Required: []string{"latitude", "longitude"},
+			},
+		},
+	}
+
+	if err := schema.validateArgs(map[string]any{"location": map[string]any{"latitude": 6.5, "longitude": 3.4}}); err != nil {
+		t.Errorf("validateArgs() returned error for valid object: %s", err.Error())
+	}
+
+	err := schema.validateArgs(map[string]any{"location": map[string]any{"latitude": 6.5}})
+	var argErr *ArgumentError
+	if !errors.As(err, &argErr) || argErr.Field != "longitude" || !argErr.Missing {
+		t.Errorf("validateArgs() error = %v, want missing longitude", err)
+	}
+
+	err = schema.validateArgs(map[string]any{"location": "Lagos"})
+	if !errors.As(err, &argErr) || argErr.Field != "location" {
+		t.Errorf("validateArgs() error = %v, want invalid location", err)
+	}
+}
+
+func TestCheckArgsType(t *testing.T) {
+	if err := purchaseSchema.CheckArgsType(reflect.TypeOf(&purchaseArgs{})); err != nil {
+		t.Errorf("CheckArgsType() returned error for matching struct: %s", err.Error())
+	}
+
+	type mismatched struct {
+		EventID  string `json:"eventId"`
+		Quantity int    `json:"quantity"`
+		Seat     string `json:"seat"`
+	}
+
+	err := purchaseSchema.CheckArgsType(reflect.TypeOf(mismatched{}))
+	if err == nil {
+		t.Fatal("CheckArgsType() returned no error for mismatched struct")
+	}
+
+	for _, problem := range []string{
+		`parameter "eventId" of type integer cannot be decoded into string`,
+		`parameter "tierName" has no struct field`,
+		`struct field "seat" is not a declared parameter`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("CheckArgsType() error = %q, want it to contain %q", err.Error(), problem)
+		}
+	}
+}
+
+func TestMalformedToolCallArguments(t *testing.T) {
+	message := openAIMessage{Role: "assistant"}
+	message.ToolCalls = make([]openAIToolCall, 1)
+	message.ToolCalls[0].ID = "call_1"
+	message.ToolCalls[0].Function.Name = "select_event"
+	message.ToolCalls[0].Function.Arguments = `{"eventId": 12,`
+
+	if _, err := fromOpenAIMessage(message); err == nil {
+		t.Error("fromOpenAIMessage() returned no error for malformed arguments")
+	}
+
+	message.ToolCalls[0].Function.Arguments = `{"eventId": 12}`
+	got, err := fromOpenAIMessage(message)
+	if err != nil {
+		t.Fatalf("fromOpenAIMessage() returned error: %s", err.Error())
+	}
+
+	if got.ToolCall == nil || got.ToolCall.ID != "call_1" || got.ToolCall.Args["eventId"] != 12.0 {
+		t.Errorf("fromOpenAIMessage() = %+v, want select_event call with eventId 12", got.ToolCall)
+	}
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Maximum attempts per model when the model API reports a transient error
+const maxTransientAttempts = 3
+
+// Fallback tries an ordered chain of models on the same provider. Transient errors are retried
+// with backoff, and the primary model is retried once on any error before the request falls
+// through to the next model in the chain.
+type Fallback struct {
+	provider LLM
+	models   []string
+	timeout  time.Duration
+}
+
+func NewFallback(provider LLM, models []string, timeout time.Duration) *Fallback {
+	return &Fallback{provider: provider, models: models, timeout: timeout}
+}
+
+func (f *Fallback) Generate(ctx context.Context, req *Request) (*Response, error) {
+	models := f.models
+	if len(models) == 0 {
+		models = []string{req.Model}
+	}
+
+	var lastErr error
+	for i, model := range models {
+		for attempt := 1; ; attempt++ {
+			modelReq := *req
+			modelReq.Model = model
+
+			resp, err := f.generate(ctx, &modelReq)
+			if err == nil {
+				return resp, nil
+			}
+
+			// Stop trying if the caller is no longer waiting for a response
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			log.Warn().Err(err).Str("model", model).Int("attempt", attempt).Msg("Model request failed")
+			lastErr = err
+
+			retry := (IsRetryable(err) && attempt < maxTransientAttempts) || (i == 0 && attempt < 2)
+			if !retry {
+				break
+			}
+
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("All models in the fallback chain failed. Last error: %w", lastErr)
+}
+
+func (f *Fallback) generate(ctx context.Context, req *Request) (*Response, error) {
+	if f.timeout <= 0 {
+		return f.provider.Generate(ctx, req)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.provider.Generate(callCtx, req)
+}
+
+// CountTokens counts the tokens of the request against the primary model in the chain
+func (f *Fallback) CountTokens(ctx context.Context, req *Request) (int, error) {
+	modelReq := *req
+	if len(f.models) > 0 {
+		modelReq.Model = f.models[0]
+	}
+
+	return CountTokens(ctx, f.provider, &modelReq)
+}
+
+// WarmCache prepares the cache of the primary model in the chain
+func (f *Fallback) WarmCache(ctx context.Context, req *Request) error {
+	cacher, ok := f.provider.(Cacher)
+	if !ok {
+		return nil
+	}
+
+	modelReq := *req
+	if len(f.models) > 0 {
+		modelReq.Model = f.models[0]
+	}
+
+	return cacher.WarmCache(ctx, &modelReq)
+}
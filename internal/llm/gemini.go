@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+type Gemini struct {
+	client       *genai.Client
+	defaultModel string
+	cache        *geminiCacheStore
+}
+
+func NewGemini(apiKey, defaultModel string) (*Gemini, error) {
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:  apiKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing Gemini client: %s", err.Error())
+	}
+
+	return &Gemini{client: client, defaultModel: defaultModel}, nil
+}
+
+func (g *Gemini) Generate(ctx context.Context, req *Request) (*Response, error) {
+	model := req.Model
+	if model == "" {
+		model = g.defaultModel
+	}
+
+	config := toGeminiConfig(req.Config)
+	if name := g.cachedContent(ctx, model, req); name != "" {
+		config.CachedContent = name
+	} else {
+		if req.System != "" {
+			config.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: req.System}}}
+		}
+		if len(req.Tools) > 0 {
+			config.Tools = []*genai.Tool{toGeminiTool(req.Tools)}
+		}
+	}
+
+	contents := toGeminiContents(req.Messages)
+	if len(req.Context) > 0 {
+		var parts []*genai.Part
+		for _, c := range req.Context {
+			parts = append(parts, &genai.Part{Text: c})
+		}
+		contents = append([]*genai.Content{{Role: genai.RoleUser, Parts: parts}}, contents...)
+	}
+
+	resp, err := g.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, fromGeminiError(err)
+	}
+
+	if config.CachedContent != "" {
+		g.recordCacheUsage(model, resp.UsageMetadata)
+	}
+
+	message, err := fromGeminiResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{Message: message, Model: model}, nil
+}
+
+func (g *Gemini) CountTokens(ctx context.Context, req *Request) (int, error) {
+	model := req.Model
+	if model == "" {
+		model = g.defaultModel
+	}
+
+	// System instruction and tools are not supported by the Gemini API token counter
+	resp, err := g.client.Models.CountTokens(ctx, model, toGeminiContents(req.Messages), nil)
+	if err != nil {
+		return 0, fromGeminiError(err)
+	}
+
+	overhead := EstimateTokens(&Request{System: req.System, Tools: req.Tools})
+	return int(resp.TotalTokens) + overhead, nil
+}
+
+func toGeminiConfig(c *GenerationConfig) *genai.GenerateContentConfig {
+	config := &genai.GenerateContentConfig{}
+	if c == nil {
+		return config
+	}
+
+	config.Temperature = c.Temperature
+	config.MaxOutputTokens = c.MaxOutputTokens
+
+	if c.ThinkingBudget != nil {
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: c.ThinkingBudget}
+	}
+
+	if c.SafetyThreshold != "" {
+		categories := []genai.HarmCategory{
+			genai.HarmCategoryHarassment,
+			genai.HarmCategoryHateSpeech,
+			genai.HarmCategorySexuallyExplicit,
+			genai.HarmCategoryDangerousContent,
+		}
+
+		for _, category := range categories {
+			config.SafetySettings = append(config.SafetySettings, &genai.SafetySetting{
+				Category:  category,
+				Threshold: genai.HarmBlockThreshold(c.SafetyThreshold),
+			})
+		}
+	}
+
+	return config
+}
+
+func toGeminiContents(messages []*Message) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(messages))
+
+	for _, m := range messages {
+		switch {
+		case m.ToolCall != nil:
+			contents = append(contents, &genai.Content{
+				Role: genai.RoleModel,
+				Parts: []*genai.Part{{
+					FunctionCall: &genai.FunctionCall{
+						ID:   m.ToolCall.ID,
+						Name: m.ToolCall.Name,
+						Args: m.ToolCall.Args,
+					},
+					ThoughtSignature: m.ToolCall.Signature,
+				}},
+			})
+		case m.ToolResult != nil:
+			// Gemini expects function responses to be sent back in the user's turn
+			contents = append(contents, &genai.Content{
+				Role: genai.RoleUser,
+				Parts: []*genai.Part{{
+					FunctionResponse: &genai.FunctionResponse{
+						ID:       m.ToolResult.ID,
+						Name:     m.ToolResult.Name,
+						Response: m.ToolResult.Response,
+					},
+				}},
+			})
+		case m.Role == RoleModel:
+			contents = append(contents, genai.NewContentFromText(m.Text, genai.RoleModel))
+		default:
+			contents = append(contents, genai.NewContentFromText(m.Text, genai.RoleUser))
+		}
+	}
+
+	return contents
+}
+
+func fromGeminiResponse(resp *genai.GenerateContentResponse) (*Message, error) {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil, fmt.Errorf("Error extracting model output: Empty response from Gemini API")
+	}
+
+	// Return the first function call made by the model, otherwise the text response
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.FunctionCall != nil {
+			return &Message{
+				Role: RoleModel,
+				ToolCall: &ToolCall{
+					ID:        part.FunctionCall.ID,
+					Name:      part.FunctionCall.Name,
+					Args:      part.FunctionCall.Args,
+					Signature: part.ThoughtSignature,
+				},
+			}, nil
+		}
+
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+
+	return &Message{Role: RoleModel, Text: text.String()}, nil
+}
+
+// FromGeminiContent converts a message stored in the Gemini format, which chat histories used before
+// messages were stored independently of the provider. It returns nil if the content holds no message.
+func FromGeminiContent(content *genai.Content) *Message {
+	if content == nil {
+		return nil
+	}
+
+	var text strings.Builder
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			return &Message{
+				Role: RoleModel,
+				ToolCall: &ToolCall{
+					ID:        part.FunctionCall.ID,
+					Name:      part.FunctionCall.Name,
+					Args:      part.FunctionCall.Args,
+					Signature: part.ThoughtSignature,
+				},
+			}
+		case part.FunctionResponse != nil:
+			return &Message{
+				Role: RoleTool,
+				ToolResult: &ToolResult{
+					ID:       part.FunctionResponse.ID,
+					Name:     part.FunctionResponse.Name,
+					Response: part.FunctionResponse.Response,
+				},
+			}
+		case !part.Thought:
+			text.WriteString(part.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return nil
+	}
+
+	role := RoleUser
+	if content.Role == genai.RoleModel {
+		role = RoleModel
+	}
+
+	return &Message{Role: role, Text: text.String()}
+}
+
+func toGeminiTool(tools []*Tool) *genai.Tool {
+	declarations := make([]*genai.FunctionDeclaration, 0, len(tools))
+
+	for _, t := range tools {
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  toGeminiSchema(t.Parameters),
+		})
+	}
+
+	return &genai.Tool{FunctionDeclarations: declarations}
+}
+
+func toGeminiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+
+	schema := &genai.Schema{
+		Type:        genai.Type(strings.ToUpper(string(s.Type))),
+		Description: s.Description,
+		Items:       toGeminiSchema(s.Items),
+		Enum:        s.Enum,
+		Required:    s.Required,
+	}
+
+	if s.Properties != nil {
+		schema.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			schema.Properties[name] = toGeminiSchema(prop)
+		}
+	}
+
+	return schema
+}
@@ -0,0 +1,240 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/genai"
+)
+
+const (
+	// Cached content is refreshed when it is this close to expiring
+	cacheRefreshMargin = 5 * time.Minute
+	// Delay before retrying to create cached content after a failed attempt
+	cacheRetryDelay = 10 * time.Minute
+)
+
+type geminiCache struct {
+	name      string
+	model     string
+	version   string
+	expiresAt time.Time
+	lastUsed  time.Time
+}
+
+type geminiCacheStore struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	entries  map[string]*geminiCache // Keyed by model and prompt version
+	retryAt  map[string]time.Time
+	flights  singleflight.Group // Keyed like entries, so a cache is only created or refreshed once at a time
+	requests atomic.Int64
+	hits     atomic.Int64
+}
+
+// EnableCache stores the system instruction and tools of requests in Gemini's explicit context cache,
+// so they are not sent and billed in full on every request. Caches are kept alive in the background.
+func (g *Gemini) EnableCache(ttl time.Duration) {
+	g.cache = &geminiCacheStore{
+		ttl:     ttl,
+		entries: make(map[string]*geminiCache),
+		retryAt: make(map[string]time.Time),
+	}
+
+	go g.refreshCaches()
+}
+
+func (g *Gemini) WarmCache(ctx context.Context, req *Request) error {
+	model := req.Model
+	if model == "" {
+		model = g.defaultModel
+	}
+
+	if g.cache == nil {
+		return nil
+	}
+
+	g.cachedContent(ctx, model, req)
+	return nil
+}
+
+// promptVersion identifies the system instruction and tools stored in a cache
+func promptVersion(system string, tools []*Tool) string {
+	declarations, _ := json.Marshal(tools)
+	hash := sha256.Sum256(append([]byte(system), declarations...))
+
+	return hex.EncodeToString(hash[:8])
+}
+
+// cachedContent returns the name of the cached content for the request, or an empty
+// string if the system instruction and tools must be sent with the request instead.
+func (g *Gemini) cachedContent(ctx context.Context, model string, req *Request) string {
+	if g.cache == nil || req.System == "" {
+		return ""
+	}
+
+	store := g.cache
+	version := promptVersion(req.System, req.Tools)
+	key := model + ":" + version
+
+	store.mu.Lock()
+	if entry := store.entries[key]; entry != nil {
+		entry.lastUsed = time.Now()
+		if time.Until(entry.expiresAt) > cacheRefreshMargin {
+			store.mu.Unlock()
+			return entry.name
+		}
+	} else if time.Now().Before(store.retryAt[key]) {
+		store.mu.Unlock()
+		return ""
+	}
+	store.mu.Unlock()
+
+	// Concurrent requests for the same prompt wait for a single cache to be created or refreshed.
+	// The lock is not held during calls to the API, so requests for other prompts are not blocked.
+	name, _, _ := store.flights.Do(key, func() (any, error) {
+		return g.ensureCache(context.WithoutCancel(ctx), key, model, version, req), nil
+	})
+
+	return name.(string)
+}
+
+// ensureCache refreshes the cached content of a prompt if it is about to expire, or creates it if it does not exist
+func (g *Gemini) ensureCache(ctx context.Context, key, model, version string, req *Request) string {
+	store := g.cache
+
+	// The cache may have been created or refreshed by a request that finished in the meantime
+	store.mu.Lock()
+	entry := store.entries[key]
+	retryAt := store.retryAt[key]
+	fresh := entry != nil && time.Until(entry.expiresAt) > cacheRefreshMargin
+	store.mu.Unlock()
+
+	if entry != nil {
+		if fresh {
+			return entry.name
+		}
+
+		if err := g.refreshCache(ctx, key, entry); err == nil {
+			return entry.name
+		}
+	}
+
+	if time.Now().Before(retryAt) {
+		return ""
+	}
+
+	cached, err := g.client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		TTL:               store.ttl,
+		DisplayName:       "ticketing-bot-" + version,
+		SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: req.System}}},
+		Tools:             []*genai.Tool{toGeminiTool(req.Tools)},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("model", model).Msg("Error creating cached content. Sending prompt inline")
+
+		store.mu.Lock()
+		store.retryAt[key] = time.Now().Add(cacheRetryDelay)
+		store.mu.Unlock()
+
+		return ""
+	}
+
+	store.mu.Lock()
+	store.entries[key] = &geminiCache{
+		name:      cached.Name,
+		model:     model,
+		version:   version,
+		expiresAt: cached.ExpireTime,
+		lastUsed:  time.Now(),
+	}
+	store.mu.Unlock()
+
+	log.Info().Str("model", model).Str("cache", cached.Name).Str("version", version).Msg("Created cached content")
+
+	return cached.Name
+}
+
+// refreshCache extends the expiry of a cache. A cache that cannot be refreshed is removed from the store.
+func (g *Gemini) refreshCache(ctx context.Context, key string, entry *geminiCache) error {
+	cached, err := g.client.Caches.Update(ctx, entry.name, &genai.UpdateCachedContentConfig{TTL: g.cache.ttl})
+
+	g.cache.mu.Lock()
+	defer g.cache.mu.Unlock()
+
+	if err != nil {
+		log.Warn().Err(err).Str("cache", entry.name).Msg("Error refreshing cached content")
+		if g.cache.entries[key] == entry {
+			delete(g.cache.entries, key)
+		}
+
+		return err
+	}
+
+	entry.expiresAt = cached.ExpireTime
+	return nil
+}
+
+// refreshCaches extends the caches in use before they expire. Caches of an older prompt
+// version stop being used once the prompt changes, and are deleted instead of refreshed.
+func (g *Gemini) refreshCaches() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Caches are picked under the lock, and deleted or refreshed after it is released
+		var unused []*geminiCache
+		expiring := map[string]*geminiCache{}
+
+		g.cache.mu.Lock()
+		for key, entry := range g.cache.entries {
+			if time.Since(entry.lastUsed) > g.cache.ttl {
+				unused = append(unused, entry)
+				delete(g.cache.entries, key)
+			} else if time.Until(entry.expiresAt) <= cacheRefreshMargin {
+				expiring[key] = entry
+			}
+		}
+		g.cache.mu.Unlock()
+
+		for _, entry := range unused {
+			log.Info().Str("model", entry.model).Str("version", entry.version).Msg("Deleting unused cached content")
+
+			if _, err := g.client.Caches.Delete(context.Background(), entry.name, nil); err != nil {
+				log.Warn().Err(err).Str("cache", entry.name).Msg("Error deleting cached content")
+			}
+		}
+
+		for key, entry := range expiring {
+			g.cache.flights.Do(key, func() (any, error) {
+				return nil, g.refreshCache(context.Background(), key, entry)
+			})
+		}
+	}
+}
+
+func (g *Gemini) recordCacheUsage(model string, usage *genai.GenerateContentResponseUsageMetadata) {
+	if g.cache == nil || usage == nil {
+		return
+	}
+
+	requests := g.cache.requests.Add(1)
+	hits := g.cache.hits.Load()
+	if usage.CachedContentTokenCount > 0 {
+		hits = g.cache.hits.Add(1)
+	}
+
+	log.Info().
+		Str("model", model).
+		Int32("cached_tokens", usage.CachedContentTokenCount).
+		Int32("prompt_tokens", usage.PromptTokenCount).
+		Float64("cache_hit_rate", float64(hits)/float64(requests)).
+		Msg("Context cache usage")
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestFromGeminiContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *genai.Content
+		want    *Message
+	}{
+		{
+			name:    "user text",
+			content: genai.NewContentFromText("Any concerts this weekend?", genai.RoleUser),
+			want:    &Message{Role: RoleUser, Text: "Any concerts this weekend?"},
+		},
+		{
+			name: "model text without thoughts",
+			content: &genai.Content{
+				Role:  genai.RoleModel,
+				Parts: []*genai.Part{{Text: "Thinking...", Thought: true}, {Text: "Here are some events."}},
+			},
+			want: &Message{Role: RoleModel, Text: "Here are some events."},
+		},
+		{
+			name: "function call",
+			content: &genai.Content{
+				Role: genai.RoleModel,
+				Parts: []*genai.Part{{
+					FunctionCall:     &genai.FunctionCall{ID: "call_1", Name: "select_event", Args: map[string]any{"eventId": 12.0}},
+					ThoughtSignature: []byte("sig"),
+				}},
+			},
+			want: &Message{
+				Role:     RoleModel,
+				ToolCall: &ToolCall{ID: "call_1", Name: "select_event", Args: map[string]any{"eventId": 12.0}, Signature: []byte("sig")},
+			},
+		},
+		{
+			name: "function response",
+			content: &genai.Content{
+				Role: genai.RoleUser,
+				Parts: []*genai.Part{{
+					FunctionResponse: &genai.FunctionResponse{ID: "call_1", Name: "select_event", Response: map[string]any{"title": "Afro Nation"}},
+				}},
+			},
+			want: &Message{
+				Role:       RoleTool,
+				ToolResult: &ToolResult{ID: "call_1", Name: "select_event", Response: map[string]any{"title": "Afro Nation"}},
+			},
+		},
+		{
+			name:    "empty content",
+			content: &genai.Content{Role: genai.RoleUser},
+			want:    nil,
+		},
+		{
+			name: "nil content",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromGeminiContent(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromGeminiContent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
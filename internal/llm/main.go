@@ -0,0 +1,74 @@
+package llm
+
+import "context"
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleModel Role = "model"
+	RoleTool  Role = "tool"
+)
+
+type ToolCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+	// Opaque provider metadata that must be sent back with the call in subsequent requests
+	Signature []byte `json:"signature,omitempty"`
+}
+
+type ToolResult struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// Message is a single provider-neutral turn in a conversation. A message holds either
+// text, a tool call made by the model, or the result of a tool call.
+type Message struct {
+	Role       Role        `json:"role"`
+	Text       string      `json:"text,omitempty"`
+	ToolCall   *ToolCall   `json:"toolCall,omitempty"`
+	ToolResult *ToolResult `json:"toolResult,omitempty"`
+}
+
+type Tool struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Parameters  *Schema `json:"parameters"`
+}
+
+// GenerationConfig holds the tunable parameters of a request. Nil or zero values fall back to the provider defaults.
+type GenerationConfig struct {
+	Temperature     *float32
+	MaxOutputTokens int32
+	ThinkingBudget  *int32
+	SafetyThreshold string // e.g. "BLOCK_MEDIUM_AND_ABOVE"
+}
+
+type Request struct {
+	Model    string // Uses the default model of the provider if empty
+	System   string
+	Messages []*Message
+	Tools    []*Tool
+	Config   *GenerationConfig
+	// Per-request context for the model (e.g. the current date) that is kept out of
+	// the cacheable system instructions and the stored conversation history
+	Context []string
+}
+
+type Response struct {
+	Message *Message
+	Model   string
+}
+
+// LLM is implemented by every model provider the bot can talk to
+type LLM interface {
+	Generate(ctx context.Context, req *Request) (*Response, error)
+}
+
+// Cacher is implemented by providers that can cache the static parts of a request ahead of time
+type Cacher interface {
+	WarmCache(ctx context.Context, req *Request) error
+}
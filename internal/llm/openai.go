@@ -0,0 +1,241 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI talks to any server that implements the OpenAI chat completions API,
+// including local stand-ins such as Ollama, vLLM or llama.cpp.
+type OpenAI struct {
+	baseURL      string
+	apiKey       string
+	defaultModel string
+	httpClient   *http.Client
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Parameters  *Schema `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Tools       []openAITool    `json:"tools,omitempty"`
+	Temperature *float32        `json:"temperature,omitempty"`
+	MaxTokens   int32           `json:"max_tokens,omitempty"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewOpenAI(baseURL, apiKey, defaultModel string) *OpenAI {
+	return &OpenAI{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		apiKey:       apiKey,
+		defaultModel: defaultModel,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req *Request) (*Response, error) {
+	model := req.Model
+	if model == "" {
+		model = o.defaultModel
+	}
+
+	payload := openAIRequest{
+		Model:    model,
+		Messages: toOpenAIMessages(req.System, req.Messages),
+	}
+
+	// Per-request context is sent as an additional system message after the instructions
+	if len(req.Context) > 0 {
+		text := strings.Join(req.Context, "\n")
+		contextMessage := openAIMessage{Role: "system", Content: &text}
+
+		insertAt := 0
+		if req.System != "" {
+			insertAt = 1
+		}
+		payload.Messages = append(payload.Messages[:insertAt], append([]openAIMessage{contextMessage}, payload.Messages[insertAt:]...)...)
+	}
+
+	// Thinking budget and safety settings have no equivalent in the chat completions API
+	if req.Config != nil {
+		payload.Temperature = req.Config.Temperature
+		payload.MaxTokens = req.Config.MaxOutputTokens
+	}
+
+	for _, t := range req.Tools {
+		tool := openAITool{Type: "function"}
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = t.Parameters
+
+		payload.Tools = append(payload.Tools, tool)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding chat completion request: %s", err.Error())
+	}
+
+	// Configure request details
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring new HTTP request: %s", err.Error())
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("Error sending chat completion request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("Error decoding chat completion response: %s", err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg := http.StatusText(resp.StatusCode)
+		if result.Error != nil {
+			msg = result.Error.Message
+		}
+
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("Error extracting model output: Empty response from chat completion API")
+	}
+
+	message, err := fromOpenAIMessage(result.Choices[0].Message)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Model != "" {
+		model = result.Model
+	}
+
+	return &Response{Message: message, Model: model}, nil
+}
+
+func toOpenAIMessages(system string, messages []*Message) []openAIMessage {
+	result := make([]openAIMessage, 0, len(messages)+1)
+	if system != "" {
+		result = append(result, openAIMessage{Role: "system", Content: &system})
+	}
+
+	// Histories recorded from providers without call IDs are paired up by position
+	var lastCallID string
+	unanswered := map[string]bool{}
+	for i, m := range messages {
+		switch {
+		case m.ToolCall != nil:
+			lastCallID = m.ToolCall.ID
+			if lastCallID == "" {
+				lastCallID = fmt.Sprintf("call_%d", i)
+			}
+			unanswered[lastCallID] = true
+
+			args, _ := json.Marshal(m.ToolCall.Args)
+			call := openAIToolCall{ID: lastCallID, Type: "function"}
+			call.Function.Name = m.ToolCall.Name
+			call.Function.Arguments = string(args)
+
+			result = append(result, openAIMessage{Role: "assistant", ToolCalls: []openAIToolCall{call}})
+		case m.ToolResult != nil:
+			callID := m.ToolResult.ID
+			if callID == "" {
+				callID = lastCallID
+			}
+
+			content, _ := json.Marshal(m.ToolResult.Response)
+			text := string(content)
+
+			// The API rejects tool messages that do not answer a pending call, so they are sent as user text
+			if !unanswered[callID] {
+				text = fmt.Sprintf("Result of %s: %s", m.ToolResult.Name, content)
+				result = append(result, openAIMessage{Role: "user", Content: &text})
+				continue
+			}
+
+			delete(unanswered, callID)
+			result = append(result, openAIMessage{Role: "tool", Content: &text, ToolCallID: callID})
+		case m.Role == RoleModel:
+			text := m.Text
+			result = append(result, openAIMessage{Role: "assistant", Content: &text})
+		default:
+			text := m.Text
+			result = append(result, openAIMessage{Role: "user", Content: &text})
+		}
+	}
+
+	return result
+}
+
+func fromOpenAIMessage(m openAIMessage) (*Message, error) {
+	if len(m.ToolCalls) > 0 {
+		call := m.ToolCalls[0]
+
+		var args map[string]any
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("Error parsing arguments of %s tool call: %s", call.Function.Name, err.Error())
+			}
+		}
+
+		return &Message{
+			Role:     RoleModel,
+			ToolCall: &ToolCall{ID: call.ID, Name: call.Function.Name, Args: args},
+		}, nil
+	}
+
+	var text string
+	if m.Content != nil {
+		text = *m.Content
+	}
+
+	return &Message{Role: RoleModel, Text: text}, nil
+}
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToOpenAIMessagesPairsToolResults(t *testing.T) {
+	messages := []*Message{
+		{Role: RoleUser, Text: "Find concerts in Lagos"},
+		{Role: RoleModel, ToolCall: &ToolCall{Name: "find_events", Args: map[string]any{"city": "Lagos"}}},
+		{Role: RoleTool, ToolResult: &ToolResult{Name: "find_events", Response: map[string]any{"events": []any{}}}},
+		{Role: RoleModel, Text: "I couldn't find any concerts."},
+
+		// Results without a call waiting for them, e.g. recorded by background tasks
+		{Role: RoleTool, ToolResult: &ToolResult{Name: "initiate_ticket_purchase", Response: map[string]any{"status": "SUCCESS"}}},
+		{Role: RoleTool, ToolResult: &ToolResult{ID: "call_9", Name: "select_event", Response: map[string]any{"title": "Afro Nation"}}},
+		{Role: RoleUser, Text: "Thanks"},
+	}
+
+	got := toOpenAIMessages("You are a ticketing assistant", messages)
+	if len(got) != len(messages)+1 {
+		t.Fatalf("toOpenAIMessages() returned %d messages, want %d", len(got), len(messages)+1)
+	}
+
+	call := got[2].ToolCalls
+	if len(call) != 1 || call[0].ID == "" {
+		t.Fatalf("toOpenAIMessages() tool call = %+v, want a call with an ID", call)
+	}
+
+	if got[3].Role != "tool" || got[3].ToolCallID != call[0].ID {
+		t.Errorf("toOpenAIMessages() result = %s for call %q, want tool message for call %q", got[3].Role, got[3].ToolCallID, call[0].ID)
+	}
+
+	// Every tool message must answer exactly one call made before it
+	answered := map[string]bool{}
+	for i, m := range got {
+		if m.Role != "tool" {
+			continue
+		}
+
+		if answered[m.ToolCallID] {
+			t.Errorf("Message %d answers call %q again", i, m.ToolCallID)
+		}
+		answered[m.ToolCallID] = true
+	}
+
+	for i, name := range map[int]string{5: "initiate_ticket_purchase", 6: "select_event"} {
+		if got[i].Role != "user" || got[i].ToolCallID != "" || got[i].Content == nil || !strings.Contains(*got[i].Content, name) {
+			t.Errorf("toOpenAIMessages() message %d = %+v, want user text with the %s result", i, got[i], name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// APIError is returned by providers when the model API responds with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Model API error. Error: %s Status code: %d", e.Message, e.StatusCode)
+}
+
+// IsRetryable reports whether a failed request is worth retrying after a short delay
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff returns the exponential delay with jitter before the given retry attempt
+func backoff(attempt int) time.Duration {
+	base := 500 * time.Millisecond << (attempt - 1)
+	jitter := time.Duration(rand.Int64N(int64(base / 2)))
+
+	return base + jitter
+}
+
+// sleep waits for the delay unless the context is cancelled first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func fromGeminiError(err error) error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return &APIError{StatusCode: apiErr.Code, Message: apiErr.Message}
+	}
+
+	return err
+}
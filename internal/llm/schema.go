@@ -0,0 +1,22 @@
+package llm
+
+type Type string
+
+const (
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+	TypeString  Type = "string"
+	TypeNumber  Type = "number"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+)
+
+// Schema is the subset of JSON Schema used to declare the parameters of a tool
+type Schema struct {
+	Type        Type               `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
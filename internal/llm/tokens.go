@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TokenCounter is implemented by providers that can count the tokens of a request before it is sent
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req *Request) (int, error)
+}
+
+// CountTokens uses the token counter of the provider if available, otherwise an estimate
+func CountTokens(ctx context.Context, model LLM, req *Request) (int, error) {
+	if counter, ok := model.(TokenCounter); ok {
+		return counter.CountTokens(ctx, req)
+	}
+
+	return EstimateTokens(req), nil
+}
+
+// EstimateTokens approximates the size of a request at roughly 4 characters per token
+func EstimateTokens(req *Request) int {
+	chars := len(req.System)
+	for _, m := range req.Messages {
+		chars += len(m.Text)
+
+		if m.ToolCall != nil {
+			args, _ := json.Marshal(m.ToolCall.Args)
+			chars += len(m.ToolCall.Name) + len(args)
+		}
+
+		if m.ToolResult != nil {
+			response, _ := json.Marshal(m.ToolResult.Response)
+			chars += len(m.ToolResult.Name) + len(response)
+		}
+	}
+
+	for _, t := range req.Tools {
+		params, _ := json.Marshal(t.Parameters)
+		chars += len(t.Name) + len(t.Description) + len(params)
+	}
+
+	return chars / 4
+}
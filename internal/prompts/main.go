@@ -0,0 +1,95 @@
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"text/template"
+
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+)
+
+// Each version of the prompts lives in its own directory with a system.tmpl and tools.json file
+//
+//go:embed templates
+var embedded embed.FS
+
+type Variables struct {
+	BotName    string
+	Currency   string
+	SupportURL string
+}
+
+// Prompts holds the rendered system instructions and tool declarations of a prompt version. They do not
+// depend on the current date, which is sent with each request, so they stay the same for the cached content.
+type Prompts struct {
+	version string
+	system  string
+	tools   []*llm.Tool
+}
+
+// Load parses the prompt templates of the given version. Templates are read from the
+// override directory if one is provided, otherwise from the templates embedded in the binary.
+func Load(version, overrideDir string, vars Variables) (*Prompts, error) {
+	var fsys fs.FS = os.DirFS(overrideDir)
+	if overrideDir == "" {
+		sub, err := fs.Sub(embedded, "templates")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	systemFile, err := fs.ReadFile(fsys, path.Join(version, "system.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading system instructions of prompt version %s: %s", version, err.Error())
+	}
+
+	systemTmpl, err := template.New("system").Option("missingkey=error").Parse(string(systemFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing system instructions of prompt version %s: %s", version, err.Error())
+	}
+
+	var system bytes.Buffer
+	if err := systemTmpl.Execute(&system, vars); err != nil {
+		return nil, fmt.Errorf("Error rendering system instructions of prompt version %s: %s", version, err.Error())
+	}
+
+	toolsFile, err := fs.ReadFile(fsys, path.Join(version, "tools.json"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading tool declarations of prompt version %s: %s", version, err.Error())
+	}
+
+	toolsTmpl, err := template.New("tools").Option("missingkey=error").Parse(string(toolsFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing tool declarations of prompt version %s: %s", version, err.Error())
+	}
+
+	var toolsJSON bytes.Buffer
+	if err := toolsTmpl.Execute(&toolsJSON, vars); err != nil {
+		return nil, fmt.Errorf("Error rendering tool declarations of prompt version %s: %s", version, err.Error())
+	}
+
+	var tools []*llm.Tool
+	if err := json.Unmarshal(toolsJSON.Bytes(), &tools); err != nil {
+		return nil, fmt.Errorf("Error decoding tool declarations of prompt version %s: %s", version, err.Error())
+	}
+
+	return &Prompts{version: version, system: system.String(), tools: tools}, nil
+}
+
+func (p *Prompts) Version() string {
+	return p.version
+}
+
+func (p *Prompts) Tools() []*llm.Tool {
+	return p.tools
+}
+
+func (p *Prompts) SystemInstructions() string {
+	return p.system
+}
@@ -0,0 +1,75 @@
+package prompts
+
+import (
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+)
+
+var testVars = Variables{BotName: "Tix", Currency: "NGN", SupportURL: "https://example.com/support"}
+
+// TestToolsMatchArgs checks that the functions declared in every embedded prompt version match
+// the args structs their calls are decoded into
+func TestToolsMatchArgs(t *testing.T) {
+	versions, err := fs.ReadDir(embedded, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range versions {
+		if !version.IsDir() {
+			continue
+		}
+
+		t.Run(version.Name(), func(t *testing.T) {
+			p, err := Load(version.Name(), "", testVars)
+			if err != nil {
+				t.Fatalf("Load() returned error: %s", err.Error())
+			}
+
+			declared := map[string]bool{}
+			for _, tool := range p.Tools() {
+				declared[tool.Name] = true
+
+				args, ok := dto.NewFunctionArgs(tool.Name)
+				if !ok {
+					t.Errorf("Function %s has no args struct", tool.Name)
+					continue
+				}
+
+				if tool.Parameters == nil {
+					continue
+				}
+
+				if err := tool.Parameters.CheckArgsType(reflect.TypeOf(args)); err != nil {
+					t.Errorf("Declaration of %s does not match its args struct: %s", tool.Name, err.Error())
+				}
+			}
+
+			for name := dto.FindEvents; name <= dto.InitiateTicketPurchase; name++ {
+				if !declared[name.String()] {
+					t.Errorf("Function %s is not declared", name)
+				}
+			}
+		})
+	}
+}
+
+// The system instructions are stored in the context cache, so they must not change from day to day
+func TestSystemInstructionsOmitDate(t *testing.T) {
+	p, err := Load("v1", "", testVars)
+	if err != nil {
+		t.Fatalf("Load() returned error: %s", err.Error())
+	}
+
+	now := time.Now()
+	for _, date := range []string{now.Format("January 02, 2006"), now.Format("2006-01-02")} {
+		if strings.Contains(p.SystemInstructions(), date) {
+			t.Errorf("System instructions contain the current date %q", date)
+		}
+	}
+}
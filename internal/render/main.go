@@ -0,0 +1,80 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+)
+
+type functionResponse struct {
+	Events   []*dto.Event       `json:"events"`
+	Tickets  []*dto.TicketTier  `json:"tickets"`
+	Checkout string             `json:"checkout"`
+	TierName string             `json:"tierName"`
+	Quantity int                `json:"quantity"`
+	Total    *dto.Money         `json:"total"`
+	Error    *dto.FunctionError `json:"error"`
+}
+
+// Replies to function errors the user can act on. Error messages are written for the model, so
+// errors without a reply here are left for the caller to handle.
+var errorReplies = map[string]string{
+	"NOT_FOUND":               "I couldn't find what you're looking for. Please check the details and try again.",
+	"PLACE_NOT_FOUND":         "I couldn't find that location. Please share a different place or your current location.",
+	"EVENT_NOT_SELECTED":      "Please select an event first, then choose your tickets.",
+	"TIER_NOT_FOUND":          "That ticket tier isn't available for this event. Please choose one of the listed tiers.",
+	"TIER_SOLD_OUT":           "Sorry, that ticket tier is sold out. Please choose another tier.",
+	"INSUFFICIENT_STOCK":      "There aren't enough tickets left for that quantity. Please choose a smaller quantity.",
+	"INVALID_QUANTITY":        "Please choose at least one ticket.",
+	"QUANTITY_LIMIT_EXCEEDED": "That's more tickets than can be bought at once. Please choose a smaller quantity.",
+	"SERVICE_UNAVAILABLE":     "Our ticketing service is unavailable at the moment. Please try again shortly.",
+}
+
+// FunctionResponse renders the data of a function response as a reply to the user. An empty
+// string is returned if the response holds nothing that can be rendered. Status messages in a
+// response are written for the model, and are never rendered.
+func FunctionResponse(response map[string]any) string {
+	// Responses hold typed structs when fresh from the backend service, and generic maps when read from the chat history
+	var data functionResponse
+	raw, err := json.Marshal(response)
+	if err != nil || json.Unmarshal(raw, &data) != nil {
+		return ""
+	}
+
+	switch {
+	case data.Error != nil:
+		return errorReplies[data.Error.Code]
+	case data.Checkout != "":
+		return "Here is your checkout link to complete the purchase:\n" + data.Checkout
+	case data.Tickets != nil:
+		if len(data.Tickets) == 0 {
+			return TicketTiers(data.Tickets, time.Now())
+		}
+		return "Here are the ticket tiers for this event:\n\n" + TicketTiers(data.Tickets, time.Now())
+	case data.Events != nil:
+		return Events(data.Events)
+	case data.TierName != "" && data.Quantity > 0 && data.Total != nil:
+		return fmt.Sprintf("You've selected %d x *%s* for a total of %s. Please share your email address to get your checkout link.", data.Quantity, data.TierName, data.Total)
+	default:
+		return ""
+	}
+}
+
+func Events(events []*dto.Event) string {
+	if len(events) == 0 {
+		return "I couldn't find any events matching your search."
+	}
+
+	var b strings.Builder
+	b.WriteString("Here are the events I found:\n")
+
+	for i, e := range events {
+		fmt.Fprintf(&b, "\n%d. *%s*\nDate: %s\nVenue: %s\n", i+1, e.Title, util.FormatDate(e.Date), e.Venue)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package render
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Time the ticket tiers in testdata are rendered at
+var renderedAt = time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+
+// TestTicketTiers renders the ticket tiers in each testdata/*.json file and compares
+// the result with the matching .golden file. Run with -update to rewrite the golden files.
+func TestTicketTiers(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cases) == 0 {
+		t.Fatal("No test cases found in testdata")
+	}
+
+	for _, path := range cases {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Tiers are decoded the way they are received from the backend service
+			var tiers []*dto.TicketTier
+			if err := json.Unmarshal(data, &tiers); err != nil {
+				t.Fatalf("Error parsing %s: %s", path, err.Error())
+			}
+
+			got := TicketTiers(tiers, renderedAt)
+
+			goldenPath := strings.TrimSuffix(path, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("TicketTiers(%s) mismatch\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+			}
+		})
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2*24*time.Hour + 5*time.Hour + 30*time.Minute, "2 days 5 hours"},
+		{24 * time.Hour, "1 day"},
+		{3 * 24 * time.Hour, "3 days"},
+		{time.Hour + time.Minute, "1 hour 1 minute"},
+		{2 * time.Hour, "2 hours"},
+		{time.Hour + 30*time.Second, "1 hour"},
+		{45 * time.Minute, "45 minutes"},
+		{time.Minute, "1 minute"},
+		{30 * time.Second, "less than a minute"},
+	}
+
+	for _, tt := range tests {
+		if got := countdown(tt.d); got != tt.want {
+			t.Errorf("countdown(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFunctionResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response map[string]any
+		want     string
+	}{
+		{
+			name:     "function error",
+			response: (&dto.FunctionError{Code: "TIER_SOLD_OUT", Message: "The VIP tier is sold out", Field: "tierName"}).Response(),
+			want:     "Sorry, that ticket tier is sold out. Please choose another tier.",
+		},
+		{
+			name:     "function error for the model",
+			response: (&dto.FunctionError{Code: "EVENT_MISMATCH", Message: "Ask the user to confirm the event first"}).Response(),
+			want:     "",
+		},
+		{
+			name:     "argument error",
+			response: (&dto.FunctionError{Code: "MISSING_ARGUMENT", Message: `Invalid argument "eventId": is required`, Field: "eventId"}).Response(),
+			want:     "",
+		},
+		{
+			name:     "checkout link",
+			response: map[string]any{"checkout": "https://pay.example.com/abc", "total": dto.NewMoney(50000, "NGN")},
+			want:     "Here is your checkout link to complete the purchase:\nhttps://pay.example.com/abc",
+		},
+		{
+			name: "ticket selection",
+			response: map[string]any{
+				"message":   "Ticket purchase details stored in cache",
+				"tierName":  "VIP",
+				"quantity":  2,
+				"unitPrice": dto.NewMoney(25000, "NGN"),
+				"total":     dto.NewMoney(50000, "NGN"),
+			},
+			want: "You've selected 2 x *VIP* for a total of ₦50,000.00. Please share your email address to get your checkout link.",
+		},
+		{
+			name:     "status message for the model",
+			response: map[string]any{"message": "Ticket purchase window has expired. Please restart the process"},
+			want:     "",
+		},
+		{
+			name:     "empty events",
+			response: map[string]any{"events": []*dto.Event{}},
+			want:     "I couldn't find any events matching your search.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FunctionResponse(tt.response); got != tt.want {
+				t.Errorf("FunctionResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
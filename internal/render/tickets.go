@@ -0,0 +1,86 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+)
+
+// TicketTiers renders the ticket tiers of an event as a WhatsApp message. Active discounts show the
+// discounted price next to the original price, with the time left before the discount ends.
+func TicketTiers(tiers []*dto.TicketTier, now time.Time) string {
+	if len(tiers) == 0 {
+		return "There are no tickets available for this event yet."
+	}
+
+	blocks := make([]string, 0, len(tiers))
+	for _, t := range tiers {
+		blocks = append(blocks, ticketTier(t, now))
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+func ticketTier(t *dto.TicketTier, now time.Time) string {
+	// Sold out tiers are struck through
+	if t.SoldOut {
+		return fmt.Sprintf("~%s - %s~ SOLD OUT", t.Name, t.Price.String())
+	}
+
+	var lines []string
+	if t.DiscountActive(now) {
+		lines = append(lines, fmt.Sprintf("*%s* - %s ~%s~", t.Name, t.DiscountPrice.String(), t.Price.String()))
+
+		var details []string
+		if t.DiscountExpiration != nil {
+			details = append(details, "Discount ends in "+countdown(t.DiscountExpiration.Sub(now)))
+		}
+		if t.NumberOfDiscountTickets != nil {
+			details = append(details, fmt.Sprintf("%d left at this price", *t.NumberOfDiscountTickets))
+		}
+
+		if len(details) > 0 {
+			lines = append(lines, strings.Join(details, " · "))
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf("*%s* - %s", t.Name, t.Price.String()))
+	}
+
+	if t.Benefits != nil && strings.TrimSpace(*t.Benefits) != "" {
+		lines = append(lines, "Includes: "+strings.TrimSpace(*t.Benefits))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// countdown formats the time left before a deadline, e.g. "2 days 5 hours" or "45 minutes"
+func countdown(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%s %s", plural(days, "day"), plural(hours, "hour"))
+	case days > 0:
+		return plural(days, "day")
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%s %s", plural(hours, "hour"), plural(minutes, "minute"))
+	case hours > 0:
+		return plural(hours, "hour")
+	case minutes > 0:
+		return plural(minutes, "minute")
+	default:
+		return "less than a minute"
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -16,9 +16,35 @@ type Secrets struct {
 	WhatsappUserAccessToken          string
 	WhatsappMessagingApiUrl          string
 	WhatsappBusinessAccountId        string
+	WhatsappEventTemplate            string
+	WhatsappTemplateLanguage         string
 	GeminiApiKey                     string
 	BackendServiceApiKey             string
 	BackendServiceUrl                string
+	WebhookSecret                    string
+	WebhookSecretPrevious            string
+	WebhookTolerance                 int
+	LlmProvider                      string
+	OpenAIBaseUrl                    string
+	OpenAIApiKey                     string
+	LlmModel                         string
+	LlmFallbackModels                string
+	LlmTemperature                   *float64
+	LlmMaxOutputTokens               int
+	LlmThinkingBudget                *int
+	LlmSafetyThreshold               string
+	LlmRequestTimeout                int
+	HistoryTokenBudget               int
+	GeminiCacheTTL                   int
+	PromptVersion                    string
+	PromptsDir                       string
+	BotName                          string
+	Currency                         string
+	SupportUrl                       string
+	GuardModelCheck                  bool
+	MaxTicketsPerOrder               int
+	NearbyRadiusKm                   float64
+	LocationTTL                      int
 }
 
 func Load() *Secrets {
@@ -30,9 +56,35 @@ func Load() *Secrets {
 		WhatsappUserAccessToken:          GetStr("WHATSAPP_USER_ACCESS_TOKEN"),
 		WhatsappMessagingApiUrl:          GetStr("WHATSAPP_MESSAGING_API_URL"),
 		WhatsappBusinessAccountId:        GetStr("WHATSAPP_BUSINESS_ACCOUNT_ID"),
+		WhatsappEventTemplate:            GetStrOrDefault("WHATSAPP_EVENT_TEMPLATE", "event_update"),
+		WhatsappTemplateLanguage:         GetStrOrDefault("WHATSAPP_TEMPLATE_LANGUAGE", "en"),
 		GeminiApiKey:                     GetStr("GEMINI_API_KEY"),
 		BackendServiceApiKey:             GetStr("BACKEND_SERVICE_API_KEY"),
 		BackendServiceUrl:                GetStr("BACKEND_SERVICE_URL"),
+		WebhookSecret:                    GetStr("WEBHOOK_SECRET"),
+		WebhookSecretPrevious:            GetStrOrDefault("WEBHOOK_SECRET_PREVIOUS", ""),
+		WebhookTolerance:                 GetIntOrDefault("WEBHOOK_TOLERANCE_SECONDS", 300),
+		LlmProvider:                      GetStrOrDefault("LLM_PROVIDER", "gemini"),
+		OpenAIBaseUrl:                    GetStrOrDefault("OPENAI_BASE_URL", "http://localhost:11434/v1"),
+		OpenAIApiKey:                     GetStrOrDefault("OPENAI_API_KEY", ""),
+		LlmModel:                         GetStrOrDefault("LLM_MODEL", ""),
+		LlmFallbackModels:                GetStrOrDefault("LLM_FALLBACK_MODELS", ""),
+		LlmTemperature:                   GetOptionalFloat("LLM_TEMPERATURE"),
+		LlmMaxOutputTokens:               GetIntOrDefault("LLM_MAX_OUTPUT_TOKENS", 0),
+		LlmThinkingBudget:                GetOptionalInt("LLM_THINKING_BUDGET"),
+		LlmSafetyThreshold:               GetStrOrDefault("LLM_SAFETY_THRESHOLD", ""),
+		LlmRequestTimeout:                GetIntOrDefault("LLM_REQUEST_TIMEOUT_SECONDS", 20),
+		HistoryTokenBudget:               GetIntOrDefault("HISTORY_TOKEN_BUDGET", 8000),
+		GeminiCacheTTL:                   GetIntOrDefault("GEMINI_CACHE_TTL_MINUTES", 60),
+		PromptVersion:                    GetStrOrDefault("PROMPT_VERSION", "v1"),
+		PromptsDir:                       GetStrOrDefault("PROMPTS_DIR", ""),
+		BotName:                          GetStrOrDefault("BOT_NAME", "Tejiri"),
+		Currency:                         GetStrOrDefault("CURRENCY", "Nigerian Naira (₦)"),
+		SupportUrl:                       GetStrOrDefault("SUPPORT_URL", ""),
+		GuardModelCheck:                  GetBoolOrDefault("GUARD_MODEL_CHECK", false),
+		MaxTicketsPerOrder:               GetIntOrDefault("MAX_TICKETS_PER_ORDER", 10),
+		NearbyRadiusKm:                   GetFloatOrDefault("NEARBY_SEARCH_RADIUS_KM", 10),
+		LocationTTL:                      GetIntOrDefault("LOCATION_TTL_HOURS", 24),
 	}
 }
 
@@ -46,6 +98,15 @@ func GetStr(key string) string {
 	return value
 }
 
+func GetStrOrDefault(key, fallback string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	return value
+}
+
 func GetInt(key string) int {
 	strValue := GetStr(key)
 
@@ -56,3 +117,61 @@ func GetInt(key string) int {
 
 	return int(intValue)
 }
+
+func GetIntOrDefault(key string, fallback int) int {
+	if value := GetOptionalInt(key); value != nil {
+		return *value
+	}
+
+	return fallback
+}
+
+func GetOptionalInt(key string) *int {
+	strValue := GetStrOrDefault(key, "")
+	if strValue == "" {
+		return nil
+	}
+
+	intValue, err := strconv.Atoi(strValue)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Invalid integer value for environment variable: %s", key)
+	}
+
+	return &intValue
+}
+
+func GetBoolOrDefault(key string, fallback bool) bool {
+	strValue := GetStrOrDefault(key, "")
+	if strValue == "" {
+		return fallback
+	}
+
+	boolValue, err := strconv.ParseBool(strValue)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Invalid boolean value for environment variable: %s", key)
+	}
+
+	return boolValue
+}
+
+func GetFloatOrDefault(key string, fallback float64) float64 {
+	if value := GetOptionalFloat(key); value != nil {
+		return *value
+	}
+
+	return fallback
+}
+
+func GetOptionalFloat(key string) *float64 {
+	strValue := GetStrOrDefault(key, "")
+	if strValue == "" {
+		return nil
+	}
+
+	floatValue, err := strconv.ParseFloat(strValue, 64)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Invalid float value for environment variable: %s", key)
+	}
+
+	return &floatValue
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// How long responses of each backend endpoint are cached. Ticket tiers are only cached
+// briefly, since the remaining stock changes with every purchase.
+const (
+	trendingEventsTTL = 5 * time.Minute
+	eventSearchTTL    = 2 * time.Minute
+	ticketTiersTTL    = 30 * time.Second
+)
+
+const (
+	backendCachePrefix = "backend_cache:"
+	// Set of all cached backend responses, used to find the keys to delete when events change
+	backendCacheIndex = "backend_cache_index"
+	// Deadline for a backend request shared by concurrent callers, including its retries
+	sharedRequestTimeout = 30 * time.Second
+)
+
+func (r ApiResponse) MarshalBinary() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func ticketTiersPath(eventId int) string {
+	return fmt.Sprintf("/events/%d/tickets", eventId)
+}
+
+// getCached returns the cached response of a GET request to the backend service, or sends the request
+// and caches its response. Concurrent identical requests share a single request to the backend service.
+func (s *ContextService) getCached(ctx context.Context, path string, ttl time.Duration, errorMsg string) (ApiResponse, error) {
+	cacheKey := backendCachePrefix + path
+
+	result, err := s.cache.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var response ApiResponse
+		parseErr := json.Unmarshal([]byte(result), &response)
+		if parseErr == nil {
+			return response, nil
+		}
+
+		log.Warn().Err(parseErr).Str("path", path).Msg("Error parsing cached backend response")
+	} else if err != redis.Nil {
+		log.Warn().Err(err).Msg("Error fetching backend response from cache")
+	}
+
+	// The shared request is detached from the context of the first caller, so that it is
+	// not cancelled for the other callers waiting on it
+	ch := s.inflight.DoChan(path, func() (any, error) {
+		requestCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedRequestTimeout)
+		defer cancel()
+
+		response, err := s.sendRequest(requestCtx, "GET", path, nil, errorMsg)
+		if err != nil {
+			return ApiResponse{}, err
+		}
+
+		s.storeCached(requestCtx, path, response, ttl)
+		return response, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ApiResponse{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return ApiResponse{}, res.Err
+		}
+
+		return res.Val.(ApiResponse), nil
+	}
+}
+
+// storeCached caches a backend response. Failures are only logged, since the response can still be used.
+func (s *ContextService) storeCached(ctx context.Context, path string, response ApiResponse, ttl time.Duration) {
+	cacheKey := backendCachePrefix + path
+
+	_, err := s.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, cacheKey, response, ttl)
+		pipe.SAdd(ctx, backendCacheIndex, cacheKey)
+		// The index outlives every key added to it, and expires once nothing has been cached for a while
+		pipe.Expire(ctx, backendCacheIndex, trendingEventsTTL)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Error storing backend response in cache")
+	}
+}
+
+// InvalidateEvents deletes the cached ticket tiers of the given events and all cached lists of events,
+// since any of them may include the changed events. All cached responses are deleted if no IDs are given.
+func (s *ContextService) InvalidateEvents(ctx context.Context, eventIds ...int) error {
+	keys, err := s.cache.SMembers(ctx, backendCacheIndex).Result()
+	if err != nil {
+		return fmt.Errorf("Error fetching cached backend responses: %s", err.Error())
+	}
+
+	tierKeys := make(map[string]bool, len(eventIds))
+	for _, id := range eventIds {
+		tierKeys[backendCachePrefix+ticketTiersPath(id)] = true
+	}
+
+	var stale []string
+	for _, key := range keys {
+		isTiers := strings.HasSuffix(key, "/tickets")
+		if len(eventIds) == 0 || !isTiers || tierKeys[key] {
+			stale = append(stale, key)
+		}
+	}
+
+	// Tiers of the events may have been cached after the index was read
+	for key := range tierKeys {
+		stale = append(stale, key)
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(stale))
+	for i, key := range stale {
+		members[i] = key
+	}
+
+	_, err = s.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, stale...)
+		pipe.SRem(ctx, backendCacheIndex, members...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting cached backend responses: %s", err.Error())
+	}
+
+	log.Info().Ints("event_ids", eventIds).Int("keys", len(stale)).Msg("Invalidated cached backend responses")
+	return nil
+}
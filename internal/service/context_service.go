@@ -1,21 +1,25 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/backend"
+	"github.com/xerdin442/ticketing-bot/internal/geo"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
 	"github.com/xerdin442/ticketing-bot/internal/secrets"
 	"github.com/xerdin442/ticketing-bot/internal/util"
-	"google.golang.org/genai"
+	"golang.org/x/sync/singleflight"
 )
 
 type ApiResponse struct {
@@ -26,95 +30,194 @@ type ApiResponse struct {
 }
 
 type ContextService struct {
-	env        *secrets.Secrets
-	cache      *redis.Client
-	httpClient *http.Client
+	env      *secrets.Secrets
+	cache    *redis.Client
+	backend  *backend.Client
+	schemas  map[string]*llm.Schema // Parameters of the functions declared to the model, keyed by name
+	geocoder geo.Geocoder
+	inflight singleflight.Group // Concurrent identical backend requests, keyed by path
 }
 
 func NewContextService(s *secrets.Secrets, r *redis.Client) *ContextService {
+	gazetteer, err := geo.NewGazetteer()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading gazetteer for place searches")
+	}
+
 	return &ContextService{
-		env:        s,
-		cache:      r,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		env:      s,
+		cache:    r,
+		backend:  backend.NewClient(s.BackendServiceUrl, s.BackendServiceApiKey),
+		geocoder: gazetteer,
 	}
 }
 
-func (s *ContextService) sendRequest(method, path string, body io.Reader, errorMsg string) (ApiResponse, error) {
-	// Configure request details
-	req, err := http.NewRequest(method, s.env.BackendServiceUrl+path, body)
-	if err != nil {
-		return ApiResponse{}, fmt.Errorf("Error configuring new HTTP request: %s", err.Error())
+func (s *ContextService) sendRequest(ctx context.Context, method, path string, body []byte, errorMsg string) (ApiResponse, error) {
+	var result ApiResponse
+	if err := s.backend.Do(ctx, method, path, body, &result); err != nil {
+		return ApiResponse{}, fmt.Errorf("%s. %w", errorMsg, err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.env.BackendServiceApiKey)
+	return result, nil
+}
 
-	// Send request to backend service
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return ApiResponse{}, fmt.Errorf("Error sending request to backend service: %s", err.Error())
+// functionError maps a failed backend request to an error the model can explain to the user,
+// or returns nil if the failure is not one the model can act on
+func functionError(err error) *dto.FunctionError {
+	switch {
+	case errors.Is(err, backend.ErrNotFound):
+		return &dto.FunctionError{
+			Code:    "NOT_FOUND",
+			Message: "The requested event or ticket tiers could not be found. The event may have ended or been removed",
+		}
+	case errors.Is(err, backend.ErrValidation):
+		var backendErr *backend.Error
+		message := "The request was rejected by the ticketing service"
+		if errors.As(err, &backendErr) {
+			message += ": " + backendErr.Message
+		}
+
+		return &dto.FunctionError{Code: "REQUEST_REJECTED", Message: message}
+	case errors.Is(err, backend.ErrUnavailable):
+		return &dto.FunctionError{
+			Code:    "SERVICE_UNAVAILABLE",
+			Message: "The ticketing service is temporarily unavailable. Please try again in a few minutes",
+		}
+	default:
+		return nil
 	}
-	defer resp.Body.Close()
+}
 
-	// Decode response body
-	var result ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return ApiResponse{}, fmt.Errorf("Error decoding repsonse body: %s", err.Error())
+// RegisterTools records the parameters of the functions declared to the model, and checks that they
+// match the args structs the function calls are decoded into
+func (s *ContextService) RegisterTools(tools []*llm.Tool) error {
+	schemas := make(map[string]*llm.Schema, len(tools))
+
+	for _, tool := range tools {
+		args, ok := dto.NewFunctionArgs(tool.Name)
+		if !ok {
+			return fmt.Errorf("Error registering function %s: No args struct is defined for the function", tool.Name)
+		}
+
+		schema := tool.Parameters
+		if schema == nil {
+			schema = &llm.Schema{Type: llm.TypeObject}
+		}
+
+		if err := schema.CheckArgsType(reflect.TypeOf(args)); err != nil {
+			return fmt.Errorf("Error registering function %s: Declaration does not match args struct: %s", tool.Name, err.Error())
+		}
+
+		schemas[tool.Name] = schema
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("%s. Error: %s Status code: %d", errorMsg, result.Message, resp.StatusCode)
-		return ApiResponse{}, err
+	for name := dto.FindEvents; name <= dto.InitiateTicketPurchase; name++ {
+		if _, ok := schemas[name.String()]; !ok {
+			return fmt.Errorf("Error registering functions: Missing declaration of function %s", name)
+		}
 	}
 
-	return result, nil
+	s.schemas = schemas
+	return nil
 }
 
-func (s *ContextService) SelectEndpoint(funcCall *genai.FunctionCall, phoneId string) (map[string]any, error) {
-	switch funcCall.Name {
-	case dto.FindEvents.String():
-		return s.FindEventsByFilters(funcCall.Args)
-	case dto.FindTrendingEvents.String():
-		return s.GetTrendingEvents()
-	case dto.SelectEvent.String():
-		return s.SelectEvent(funcCall.Args["eventId"])
-	case dto.SelectTicketTier.String():
-		return s.SelectTicketTier(funcCall.Args, phoneId)
-	case dto.InitiateTicketPurchase.String():
-		return s.InitiateTicketPurchase(funcCall.Args["email"], phoneId)
+// decodeArgs decodes the arguments of a function call into its args struct
+func (s *ContextService) decodeArgs(funcCall *llm.ToolCall) (any, *dto.FunctionError) {
+	args, ok := dto.NewFunctionArgs(funcCall.Name)
+	if !ok {
+		return nil, &dto.FunctionError{Code: "UNKNOWN_FUNCTION", Message: fmt.Sprintf("Function %s does not exist", funcCall.Name)}
+	}
+
+	err := llm.DecodeArgs(s.schemas[funcCall.Name], funcCall.Args, args)
+	if err == nil {
+		return args, nil
+	}
+
+	var fnErr *dto.FunctionError
+	var argErr *llm.ArgumentError
+	switch {
+	case errors.As(err, &fnErr):
+		return nil, fnErr
+	case errors.As(err, &argErr) && argErr.Missing:
+		return nil, &dto.FunctionError{Code: "MISSING_ARGUMENT", Message: argErr.Error(), Field: argErr.Field}
+	case errors.As(err, &argErr):
+		return nil, &dto.FunctionError{Code: "INVALID_ARGUMENT", Message: argErr.Error(), Field: argErr.Field}
+	default:
+		return nil, &dto.FunctionError{Code: "INVALID_ARGUMENT", Message: err.Error()}
+	}
+}
+
+func (s *ContextService) SelectEndpoint(ctx context.Context, funcCall *llm.ToolCall, phoneId string) (map[string]any, error) {
+	// Malformed arguments are passed back to the model to correct
+	decoded, fnErr := s.decodeArgs(funcCall)
+	if fnErr != nil {
+		return fnErr.Response(), nil
+	}
+
+	apiContext, err := s.callEndpoint(ctx, decoded, phoneId)
+	if fnErr := functionError(err); fnErr != nil {
+		log.Warn().Err(err).Str("function", funcCall.Name).Msg("Backend request failed. Passing error to model")
+		return fnErr.Response(), nil
+	}
+
+	return apiContext, err
+}
+
+func (s *ContextService) callEndpoint(ctx context.Context, decoded any, phoneId string) (map[string]any, error) {
+	switch args := decoded.(type) {
+	case *dto.FindEventsArgs:
+		return s.FindEventsByFilters(ctx, args, phoneId)
+	case *dto.FindNearbyEventsArgs:
+		return s.GetEventsNearPlace(ctx, args.Place, args.RadiusKm)
+	case *dto.FindTrendingEventsArgs:
+		return s.GetTrendingEvents(ctx)
+	case *dto.SelectEventArgs:
+		return s.SelectEvent(ctx, args.EventID, phoneId)
+	case *dto.SelectTicketTierArgs:
+		return s.SelectTicketTier(ctx, args, phoneId)
+	case *dto.InitiateTicketPurchaseArgs:
+		return s.InitiateTicketPurchase(ctx, args.Email, phoneId)
 	default:
 		err := fmt.Errorf("Error selecting endpoint in context service: Invalid function name")
 		return nil, err
 	}
 }
 
-func (s *ContextService) FindEventsByFilters(args map[string]any) (map[string]any, error) {
-	params := url.Values{}
+func (s *ContextService) FindEventsByFilters(ctx context.Context, args *dto.FindEventsArgs, phoneId string) (map[string]any, error) {
+	// Validate date filters before they are sent to the backend service
+	if args.StartDate != "" || args.EndDate != "" {
+		now := time.Now().In(util.UserLocation(phoneId))
 
-	// Add filter as search params
-	for key, value := range args {
-		// Map 'numberOfQueries' parameter as 'page'
-		param := key
-		if key == "numberOfQueries" {
-			param = "page"
+		start, end, err := util.ValidateDateRange(args.StartDate, args.EndDate, now)
+		if err != nil {
+			fnErr := &dto.FunctionError{Code: "INVALID_DATE_RANGE", Message: err.Error(), Field: "startDate"}
+			return map[string]any{"events": []*dto.Event{}, "error": fnErr}, nil
 		}
 
-		switch v := value.(type) {
-		case []string:
-			for _, item := range v {
-				params.Add(param, item)
-			}
-		case string:
-			params.Set(param, v)
-		default:
-			if v != nil {
-				params.Set(param, fmt.Sprintf("%v", v))
-			}
+		args.StartDate, args.EndDate = start, end
+	}
+
+	// Add filters as search params. 'numberOfQueries' is mapped as 'page'
+	params := url.Values{}
+	for _, category := range args.Categories {
+		params.Add("categories", category)
+	}
+
+	for key, value := range map[string]string{
+		"startDate":  args.StartDate,
+		"endDate":    args.EndDate,
+		"eventTitle": args.EventTitle,
+		"location":   args.Location,
+		"venue":      args.Venue,
+	} {
+		if value != "" {
+			params.Set(key, value)
 		}
 	}
+	params.Set("page", strconv.Itoa(args.NumberOfQueries))
 
 	urlPath := "/events?" + params.Encode()
-	response, err := s.sendRequest("GET", urlPath, nil, "Error finding events by filter")
+	response, err := s.getCached(ctx, urlPath, eventSearchTTL, "Error finding events by filter")
 	if err != nil {
 		return nil, err
 	}
@@ -122,19 +225,52 @@ func (s *ContextService) FindEventsByFilters(args map[string]any) (map[string]an
 	return map[string]any{"events": response.Events}, nil
 }
 
-func (s *ContextService) GetNearbyEvents(latitude, longitude int) (map[string]any, error) {
-	urlPath := fmt.Sprintf("/events/nearby?latitude=%d&longitude=%d", latitude, longitude)
-	response, err := s.sendRequest("GET", urlPath, nil, "Error fetching nearby events")
+func (s *ContextService) GetNearbyEvents(ctx context.Context, origin geo.Point, radiusKm float64) (map[string]any, error) {
+	if radiusKm <= 0 {
+		radiusKm = s.env.NearbyRadiusKm
+	}
+
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(origin.Latitude, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(origin.Longitude, 'f', -1, 64))
+	params.Set("radius", strconv.FormatFloat(radiusKm, 'f', -1, 64))
+
+	urlPath := "/events/nearby?" + params.Encode()
+	response, err := s.sendRequest(ctx, "GET", urlPath, nil, "Error fetching nearby events")
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]any{"events": response.Events}, nil
+	events := sortByDistance(origin, radiusKm, response.Events)
+	return map[string]any{"events": events, "radiusKm": radiusKm}, nil
+}
+
+// GetEventsNearPlace geocodes a place named by the user and fetches the events around it
+func (s *ContextService) GetEventsNearPlace(ctx context.Context, query string, radiusKm float64) (map[string]any, error) {
+	place, err := s.geocoder.Geocode(ctx, query)
+	if errors.Is(err, geo.ErrPlaceNotFound) {
+		fnErr := &dto.FunctionError{
+			Code:    "PLACE_NOT_FOUND",
+			Message: fmt.Sprintf("%q is not a known town, city or neighbourhood", query),
+			Field:   "place",
+		}
+		return map[string]any{"events": []*dto.Event{}, "error": fnErr}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error geocoding place name: %s", err.Error())
+	}
+
+	apiContext, err := s.GetNearbyEvents(ctx, place.Point, radiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	apiContext["place"] = place.Label()
+	return apiContext, nil
 }
 
-func (s *ContextService) GetTrendingEvents() (map[string]any, error) {
+func (s *ContextService) GetTrendingEvents(ctx context.Context) (map[string]any, error) {
 	errorMsg := "Error fetching all trending events"
-	response, err := s.sendRequest("GET", "/events/trending", nil, errorMsg)
+	response, err := s.getCached(ctx, "/events/trending", trendingEventsTTL, errorMsg)
 	if err != nil {
 		return nil, err
 	}
@@ -142,32 +278,145 @@ func (s *ContextService) GetTrendingEvents() (map[string]any, error) {
 	return map[string]any{"events": response.Events}, nil
 }
 
-func (s *ContextService) SelectEvent(eventId any) (map[string]any, error) {
-	urlPath := fmt.Sprintf("/events/%d/tickets", eventId)
+func (s *ContextService) SelectEvent(ctx context.Context, eventId int, phoneId string) (map[string]any, error) {
 	errorMsg := "Error fetching available ticket tiers for an event"
-
-	response, err := s.sendRequest("GET", urlPath, nil, errorMsg)
+	response, err := s.getCached(ctx, ticketTiersPath(eventId), ticketTiersTTL, errorMsg)
 	if err != nil {
 		return nil, err
 	}
 
+	// Remember the selected event so the ticket tier selection can be checked against it
+	cacheKey := "selected_event:" + util.CreateHashedKey(phoneId)
+	if err := s.cache.Set(ctx, cacheKey, eventId, time.Hour*3).Err(); err != nil {
+		return nil, fmt.Errorf("Error storing selected event in cache: %s", err.Error())
+	}
+
+	// Track the conversations on the event, so users can be notified when the event changes
+	conversationsKey := fmt.Sprintf("event_conversations:%d", eventId)
+	_, err = s.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, conversationsKey, phoneId)
+		pipe.Expire(ctx, conversationsKey, time.Hour*3)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Int("event_id", eventId).Msg("Error tracking conversation on selected event")
+	}
+
 	return map[string]any{"tickets": response.Tickets}, nil
 }
 
-func (s *ContextService) SelectTicketTier(args map[string]any, phoneId string) (map[string]any, error) {
-	cacheKey := "ticket_purchase:" + util.CreateHashedKey(phoneId)
+func (s *ContextService) SelectTicketTier(ctx context.Context, args *dto.SelectTicketTierArgs, phoneId string) (map[string]any, error) {
+	eventId := args.EventID
+
+	// The purchase must be for the event the user selected earlier
+	selected, err := s.cache.Get(ctx, "selected_event:"+util.CreateHashedKey(phoneId)).Result()
+	if err == redis.Nil {
+		fnErr := &dto.FunctionError{Code: "EVENT_NOT_SELECTED", Message: "No event has been selected. Ask the user to select an event first", Field: "eventId"}
+		return fnErr.Response(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error fetching selected event from cache: %s", err.Error())
+	}
+
+	if selected != strconv.Itoa(eventId) {
+		fnErr := &dto.FunctionError{
+			Code:    "EVENT_MISMATCH",
+			Message: fmt.Sprintf("Event ID %d does not match the selected event with ID %s", eventId, selected),
+			Field:   "eventId",
+		}
+		return fnErr.Response(), nil
+	}
+
+	// Fetch the current prices and availability of the event's ticket tiers, bypassing the cache
+	urlPath := ticketTiersPath(eventId)
+	response, err := s.sendRequest(ctx, "GET", urlPath, nil, "Error fetching available ticket tiers for an event")
+	if err != nil {
+		return nil, err
+	}
+	s.storeCached(ctx, urlPath, response, ticketTiersTTL)
 
-	if _, err := s.cache.Set(context.Background(), cacheKey, args, time.Hour*3).Result(); err != nil {
+	tier, fnErr := s.validateTicketOrder(response.Tickets, args.TierName, args.Quantity)
+	if fnErr != nil {
+		return fnErr.Response(), nil
+	}
+
+	// Line total is computed from the discounted price while the discount is active
+	now := time.Now()
+	purchase := dto.TicketPurchase{
+		EventID:   eventId,
+		TierName:  tier.Name,
+		Quantity:  args.Quantity,
+		UnitPrice: tier.UnitPrice(now),
+		Total:     tier.LineTotal(args.Quantity, now),
+	}
+
+	cacheKey := "ticket_purchase:" + util.CreateHashedKey(phoneId)
+	if _, err := s.cache.Set(ctx, cacheKey, purchase, time.Hour*3).Result(); err != nil {
 		return nil, fmt.Errorf("Error storing ticket purchase details in cache")
 	}
 
-	return map[string]any{"message": "Ticket purchase details stored in cache"}, nil
+	return map[string]any{
+		"message":   "Ticket purchase details stored in cache",
+		"tierName":  purchase.TierName,
+		"quantity":  purchase.Quantity,
+		"unitPrice": purchase.UnitPrice,
+		"total":     purchase.Total,
+	}, nil
+}
+
+// validateTicketOrder checks the selected tier and quantity against the ticket tiers of the event
+func (s *ContextService) validateTicketOrder(tiers []*dto.TicketTier, tierName string, quantity int) (*dto.TicketTier, *dto.FunctionError) {
+	var tier *dto.TicketTier
+	for _, t := range tiers {
+		if strings.EqualFold(strings.TrimSpace(t.Name), strings.TrimSpace(tierName)) {
+			tier = t
+			break
+		}
+	}
+
+	if tier == nil {
+		names := make([]string, 0, len(tiers))
+		for _, t := range tiers {
+			names = append(names, t.Name)
+		}
+
+		return nil, &dto.FunctionError{
+			Code:    "TIER_NOT_FOUND",
+			Message: fmt.Sprintf("Ticket tier %q does not exist for this event. Available tiers: %s", tierName, strings.Join(names, ", ")),
+			Field:   "tierName",
+		}
+	}
+
+	if tier.SoldOut {
+		return nil, &dto.FunctionError{Code: "TIER_SOLD_OUT", Message: fmt.Sprintf("The %s tier is sold out", tier.Name), Field: "tierName"}
+	}
+
+	if quantity < 1 {
+		return nil, &dto.FunctionError{Code: "INVALID_QUANTITY", Message: "Quantity must be at least 1", Field: "quantity"}
+	}
+
+	if quantity > s.env.MaxTicketsPerOrder {
+		return nil, &dto.FunctionError{
+			Code:    "QUANTITY_LIMIT_EXCEEDED",
+			Message: fmt.Sprintf("A maximum of %d tickets can be bought in a single order", s.env.MaxTicketsPerOrder),
+			Field:   "quantity",
+		}
+	}
+
+	if remaining, ok := tier.Remaining(); ok && quantity > remaining {
+		return nil, &dto.FunctionError{
+			Code:    "INSUFFICIENT_STOCK",
+			Message: fmt.Sprintf("Only %d tickets are left in the %s tier", remaining, tier.Name),
+			Field:   "quantity",
+		}
+	}
+
+	return tier, nil
 }
 
-func (s *ContextService) InitiateTicketPurchase(email any, phoneId string) (map[string]any, error) {
+func (s *ContextService) InitiateTicketPurchase(ctx context.Context, email string, phoneId string) (map[string]any, error) {
 	cacheKey := "ticket_purchase:" + util.CreateHashedKey(phoneId)
 
-	cacheResult, err := s.cache.Get(context.Background(), cacheKey).Result()
+	cacheResult, err := s.cache.Get(ctx, cacheKey).Result()
 	if err == redis.Nil {
 		return map[string]any{
 			"message": "Ticket purchase window has expired. Please restart the process",
@@ -177,28 +426,30 @@ func (s *ContextService) InitiateTicketPurchase(email any, phoneId string) (map[
 	}
 
 	// Extract purchase details from cache result
-	var details map[string]any
+	var details dto.TicketPurchase
 	if err := json.Unmarshal([]byte(cacheResult), &details); err != nil {
 		return nil, fmt.Errorf("Error parsing purchase details stored in cache: %s", err.Error())
 	}
 
-	// Configure request payload
-	quantity, _ := strconv.Atoi(fmt.Sprintf("%v", details["quantity"]))
+	// Configure request payload. Amounts are sent in major units, as the backend service sends prices
 	payload := map[string]any{
-		"tier":            details["tierName"],
-		"quantity":        quantity,
+		"tier":            details.TierName,
+		"quantity":        details.Quantity,
 		"email":           email,
 		"whatsappPhoneId": phoneId,
+		"unitPrice":       details.UnitPrice.Major(),
+		"amount":          details.Total.Major(),
+		"currency":        details.Total.Currency,
 	}
 
 	body, _ := json.Marshal(payload)
-	urlPath := fmt.Sprintf("/events/%v/tickets/purchase", details["eventId"])
+	urlPath := fmt.Sprintf("/events/%d/tickets/purchase", details.EventID)
 	errorMsg := "Error generating checkout link for ticket purchase"
 
-	response, err := s.sendRequest("POST", urlPath, bytes.NewBuffer(body), errorMsg)
+	response, err := s.sendRequest(ctx, "POST", urlPath, body, errorMsg)
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]any{"checkout": response.Checkout}, nil
+	return map[string]any{"checkout": response.Checkout, "total": details.Total}, nil
 }
@@ -2,36 +2,159 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/dateparse"
+	"github.com/xerdin442/ticketing-bot/internal/guard"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+	"github.com/xerdin442/ticketing-bot/internal/prompts"
+	"github.com/xerdin442/ticketing-bot/internal/render"
 	"github.com/xerdin442/ticketing-bot/internal/secrets"
 	"github.com/xerdin442/ticketing-bot/internal/util"
-	"google.golang.org/genai"
 )
 
+const (
+	// Maximum number of function calls the model can chain in a single turn
+	maxAgentSteps = 5
+	// Time budget for all the steps in a single turn
+	agentTurnBudget = 25 * time.Second
+)
+
+// Reply sent when the model cannot produce a response in time
+const fallbackReply = "Sorry, I am unable to process your request at the moment."
+
+// AgentResult is the outcome of a turn in the agent loop. If Pending is set, the function call
+// could not be completed by the model alone and its result must be delivered to the user directly.
+type AgentResult struct {
+	Text     string
+	Pending  *llm.ToolCall
+	Response map[string]any
+}
+
 type GeminiService struct {
-	env    *secrets.Secrets
-	cache  *redis.Client
-	client *genai.Client
+	env     *secrets.Secrets
+	cache   *redis.Client
+	model   llm.LLM
+	config  *llm.GenerationConfig
+	prompts *prompts.Prompts
+	context *ContextService
+	guard   *guard.Guard
+}
+
+func NewGeminiService(s *secrets.Secrets, r *redis.Client, c *ContextService) *GeminiService {
+	model := newModelProvider(s)
+
+	svc := &GeminiService{
+		env:     s,
+		cache:   r,
+		model:   model,
+		config:  newGenerationConfig(s),
+		prompts: loadPrompts(s),
+		context: c,
+		guard:   guard.NewGuard(model, s.GuardModelCheck),
+	}
+
+	// Function calls are decoded into args structs that must match the declarations
+	if err := c.RegisterTools(svc.prompts.Tools()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register function declarations")
+	}
+
+	// Cache the system instructions and tools ahead of the first conversation
+	if cacher, ok := svc.model.(llm.Cacher); ok {
+		go func() {
+			req := &llm.Request{System: svc.prompts.SystemInstructions(), Tools: svc.prompts.Tools()}
+			if err := cacher.WarmCache(context.Background(), req); err != nil {
+				log.Warn().Err(err).Msg("Error preparing model cache")
+			}
+		}()
+	}
+
+	return svc
+}
+
+func newModelProvider(s *secrets.Secrets) llm.LLM {
+	var provider llm.LLM
+	var defaultModels []string
+
+	switch s.LlmProvider {
+	case "gemini":
+		gemini, err := llm.NewGemini(s.GeminiApiKey, "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize model provider")
+		}
+
+		// Explicit context caching is disabled if the TTL is set to zero
+		if s.GeminiCacheTTL > 0 {
+			gemini.EnableCache(time.Duration(s.GeminiCacheTTL) * time.Minute)
+		}
+
+		provider = gemini
+		defaultModels = []string{"gemini-3-flash-preview", "gemini-2.5-flash"}
+	case "openai":
+		provider = llm.NewOpenAI(s.OpenAIBaseUrl, s.OpenAIApiKey, "")
+		defaultModels = []string{"llama3.1"}
+	default:
+		log.Fatal().Str("provider", s.LlmProvider).Msg("Unsupported model provider")
+	}
+
+	// Primary model is followed by the fallback models in order of preference
+	models := defaultModels
+	if s.LlmModel != "" {
+		models = []string{s.LlmModel}
+	}
+
+	if s.LlmFallbackModels != "" {
+		models = models[:1]
+		for _, m := range strings.Split(s.LlmFallbackModels, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				models = append(models, m)
+			}
+		}
+	} else if s.LlmModel != "" {
+		models = append(models, defaultModels[1:]...)
+	}
+
+	return llm.NewFallback(provider, models, time.Duration(s.LlmRequestTimeout)*time.Second)
 }
 
-func NewGeminiService(s *secrets.Secrets, r *redis.Client) *GeminiService {
-	client, _ := genai.NewClient(context.Background(), &genai.ClientConfig{
-		APIKey:  s.GeminiApiKey,
-		Backend: genai.BackendGeminiAPI,
+func loadPrompts(s *secrets.Secrets) *prompts.Prompts {
+	p, err := prompts.Load(s.PromptVersion, s.PromptsDir, prompts.Variables{
+		BotName:    s.BotName,
+		Currency:   s.Currency,
+		SupportURL: s.SupportUrl,
 	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load prompts")
+	}
+
+	log.Info().Str("version", p.Version()).Msg("Prompts loaded")
+	return p
+}
+
+func newGenerationConfig(s *secrets.Secrets) *llm.GenerationConfig {
+	config := &llm.GenerationConfig{
+		MaxOutputTokens: int32(s.LlmMaxOutputTokens),
+		SafetyThreshold: s.LlmSafetyThreshold,
+	}
+
+	if s.LlmTemperature != nil {
+		temperature := float32(*s.LlmTemperature)
+		config.Temperature = &temperature
+	}
 
-	return &GeminiService{
-		env:    s,
-		cache:  r,
-		client: client,
+	if s.LlmThinkingBudget != nil {
+		budget := int32(*s.LlmThinkingBudget)
+		config.ThinkingBudget = &budget
 	}
+
+	return config
 }
 
 func (s *GeminiService) GetNextStateAfterFunctionCall(funcName string) (dto.ConversationState, error) {
@@ -50,25 +173,30 @@ func (s *GeminiService) GetNextStateAfterFunctionCall(funcName string) (dto.Conv
 	}
 }
 
-func (s *GeminiService) UpdateChatHistory(phoneId string, contextInfo *dto.ConversationContext) error {
+func (s *GeminiService) UpdateChatHistory(ctx context.Context, phoneId string, contextInfo *dto.ConversationContext) error {
 	cacheKey := "chat_history:" + util.CreateHashedKey(phoneId)
 
+	// Record the version of the prompts the context was produced with
+	if contextInfo.PromptVersion == "" {
+		contextInfo.PromptVersion = s.prompts.Version()
+	}
+
 	// Update chat history in cache
-	if _, err := s.cache.RPush(context.Background(), cacheKey, contextInfo).Result(); err != nil {
+	if _, err := s.cache.RPush(ctx, cacheKey, contextInfo).Result(); err != nil {
 		return fmt.Errorf("Error updating chat history")
 	}
 
 	// Clear stored contexts in chat history after 6 hours
-	if err := s.cache.Expire(context.Background(), cacheKey, time.Hour*6).Err(); err != nil {
+	if err := s.cache.Expire(ctx, cacheKey, time.Hour*6).Err(); err != nil {
 		return fmt.Errorf("Error setting expiration time of chat context")
 	}
 
 	return nil
 }
 
-func (s *GeminiService) GetChatHistory(phoneId string) ([]dto.ConversationContext, error) {
+func (s *GeminiService) GetChatHistory(ctx context.Context, phoneId string) ([]dto.ConversationContext, error) {
 	cacheKey := "chat_history:" + util.CreateHashedKey(phoneId)
-	result, err := s.cache.LRange(context.Background(), cacheKey, 0, -1).Result()
+	result, err := s.cache.LRange(ctx, cacheKey, 0, -1).Result()
 
 	if err != nil {
 		return nil, fmt.Errorf("Error fetching chat history from cache")
@@ -76,103 +204,149 @@ func (s *GeminiService) GetChatHistory(phoneId string) ([]dto.ConversationContex
 
 	chatHistory := make([]dto.ConversationContext, 0, len(result))
 
+	var migrated int
 	for _, item := range result {
-		var contextObj dto.ConversationContext
-
-		if err := json.Unmarshal([]byte(item), &contextObj); err != nil {
+		contextObj, converted, err := decodeChatContext(item)
+		if err != nil {
 			return nil, fmt.Errorf("Unmarshal error: Invalid conversation context retrieved from cache")
 		}
 
+		if converted {
+			migrated++
+		}
+
+		// Remove contexts that hold no message, so they are not decoded on every turn
+		if contextObj.Message == nil {
+			log.Warn().Str("context", item).Msg("Removing conversation context without a message from chat history")
+			if err := s.cache.LRem(ctx, cacheKey, 1, item).Err(); err != nil {
+				log.Error().Err(err).Msg("Error removing conversation context from chat history")
+			}
+			continue
+		}
+
 		chatHistory = append(chatHistory, contextObj)
 	}
 
+	if migrated > 0 {
+		log.Info().Int("contexts", migrated).Msg("Converted chat history stored in the Gemini format")
+	}
+
 	return chatHistory, nil
 }
 
-func (s *GeminiService) GenerateModelResponse(contents []*genai.Content) (*genai.GenerateContentResponse, error) {
-	return s.client.Models.GenerateContent(
-		context.Background(),
-		"gemini-3-flash-preview",
-		contents,
-		&genai.GenerateContentConfig{
-			Tools:             []*genai.Tool{util.RequiredTools},
-			SystemInstruction: util.SystemInstructions,
-		},
+// currentTimeContext tells the model the current date and time in the user's timezone
+func currentTimeContext(phoneId string) string {
+	loc := util.UserLocation(phoneId)
+	now := time.Now().In(loc)
+
+	return fmt.Sprintf(
+		"System context: The current date and time for the user is %s (%s, UTC%s). Resolve relative dates such as \"tomorrow\" or \"this weekend\" from this date.",
+		now.Format("Monday, January 02, 2006 15:04"),
+		loc.String(),
+		now.Format("-07:00"),
 	)
 }
 
-func (s *GeminiService) ProcessUserMessage(phoneId string, userInput string) (any, error) {
+func (s *GeminiService) GenerateModelResponse(ctx context.Context, phoneId string, messages []*llm.Message) (*llm.Response, error) {
+	start := time.Now()
+	resp, err := s.model.Generate(ctx, &llm.Request{
+		System:   s.prompts.SystemInstructions(),
+		Messages: messages,
+		Tools:    s.prompts.Tools(),
+		Config:   s.config,
+		Context:  []string{currentTimeContext(phoneId)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("model", resp.Model).Dur("latency", time.Since(start)).Msg("Model response generated")
+
+	return resp, nil
+}
+
+// modelOutput returns either the text or the function call in the model's message
+func modelOutput(message *llm.Message) any {
+	if message.ToolCall != nil {
+		return message.ToolCall
+	}
+
+	return message.Text
+}
+
+func (s *GeminiService) ProcessUserMessage(ctx context.Context, phoneId string, userInput string) (any, error) {
+	// Off-topic, abusive and injection attempts are answered without the model
+	if verdict := s.guard.CheckInput(ctx, userInput); !verdict.Allowed() {
+		log.Warn().Str("category", string(verdict.Category)).Str("reason", verdict.Reason).Msg("User message blocked by input guard")
+		return verdict.Reply(), nil
+	}
+
 	currentState := dto.StateInitial
-	var contents []*genai.Content
+	var messages []*llm.Message
 
 	// Fetch current conversation history
-	chatHistory, err := s.GetChatHistory(phoneId)
+	chatHistory, err := s.GetChatHistory(ctx, phoneId)
 	if err != nil {
 		return "", err
 	}
 
-	// Extract the current state and contents of the conversation history
+	// Extract the current state and messages of the conversation history
 	if len(chatHistory) > 0 {
 		currentState = chatHistory[len(chatHistory)-1].CurrentState
-		for _, h := range chatHistory {
-			contents = append(contents, h.Content)
-		}
+		messages = s.BuildModelHistory(ctx, phoneId, chatHistory)
 	}
 
 	// Configure the context to be passed to the model
-	userContext := &genai.Content{Role: genai.RoleUser, Parts: []*genai.Part{{Text: userInput}}}
-	contents = append(contents, userContext)
+	userMessage := &llm.Message{Role: llm.RoleUser, Text: userInput}
+	messages = append(messages, userMessage)
 
 	// Generate model response
-	resp, err := s.GenerateModelResponse(contents)
+	resp, err := s.GenerateModelResponse(ctx, phoneId, messages)
 	if err != nil {
-		s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-			Content:      &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{{Text: "Response generation error"}}},
+		// Nothing is recorded if the turn was cancelled or superseded by a newer message
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+			Message:      &llm.Message{Role: llm.RoleModel, Text: "Response generation error"},
 			CurrentState: dto.StateResponseError,
 		})
 
-		log.Error().Err(err).Msg("Error generating response from Gemini API")
+		log.Error().Err(err).Msg("Error generating response from model")
 
-		return "Sorry, I am unable to process your request at the moment.", nil
+		return fallbackReply, nil
 	}
 
-	var result any
-	var modelPart *genai.Part
-	part := resp.Candidates[0].Content.Parts[0]
+	// Determine the next conversation state if the model made a function call
+	if resp.Message.ToolCall != nil {
+		// Dates mentioned by the user take precedence over the dates resolved by the model
+		applyDatePhrase(phoneId, userInput, resp.Message.ToolCall)
 
-	// Check the response if the model made a function call
-	if part.FunctionCall != nil {
-		// Determine the next conversation state based on the function call
-		currentState, err = s.GetNextStateAfterFunctionCall(part.FunctionCall.Name)
+		currentState, err = s.GetNextStateAfterFunctionCall(resp.Message.ToolCall.Name)
 		if err != nil {
 			return "", err
 		}
-
-		result = part.FunctionCall
-		modelPart = &genai.Part{FunctionCall: part.FunctionCall}
-	} else {
-		result = part.Text
-		modelPart = &genai.Part{Text: part.Text}
 	}
 
 	// Add user input to conversation history
-	s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-		Content:      userContext,
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message:      userMessage,
 		CurrentState: currentState,
 	})
 
 	// Add model response to conversation history
-	s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-		Content:      &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{modelPart}},
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message:      resp.Message,
 		CurrentState: currentState,
 	})
 
-	return result, nil
+	return modelOutput(resp.Message), nil
 }
 
-func (s *GeminiService) ProcessFunctionCall(phoneId string, apiContext map[string]any) (string, error) {
+func (s *GeminiService) ProcessFunctionCall(ctx context.Context, phoneId string, apiContext map[string]any) (any, error) {
 	// Fetch current conversation history
-	chatHistory, err := s.GetChatHistory(phoneId)
+	chatHistory, err := s.GetChatHistory(ctx, phoneId)
 	if err != nil {
 		return "", err
 	}
@@ -181,18 +355,15 @@ func (s *GeminiService) ProcessFunctionCall(phoneId string, apiContext map[strin
 		return "", fmt.Errorf("Error processing function call: Empty conversation history")
 	}
 
-	// Extract the current state and contents of the conversation history
+	// Extract the current state and messages of the conversation history
 	currentState := chatHistory[len(chatHistory)-1].CurrentState
-	var contents []*genai.Content
-	for _, h := range chatHistory {
-		contents = append(contents, h.Content)
-	}
+	messages := s.BuildModelHistory(ctx, phoneId, chatHistory)
 
 	// Retrieve details of last function call
-	var lastFunctionCall *genai.FunctionCall
-	for i := len(contents) - 1; i >= 0; i-- {
-		if contents[i].Role == genai.RoleModel && contents[i].Parts[0].FunctionCall != nil {
-			lastFunctionCall = contents[i].Parts[0].FunctionCall
+	var lastFunctionCall *llm.ToolCall
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].ToolCall != nil {
+			lastFunctionCall = messages[i].ToolCall
 			break
 		}
 	}
@@ -203,43 +374,248 @@ func (s *GeminiService) ProcessFunctionCall(phoneId string, apiContext map[strin
 	}
 
 	// Configure the context to be passed to the model
-	functionContent := &genai.Content{
-		Role: "system",
-		Parts: []*genai.Part{{
-			FunctionResponse: &genai.FunctionResponse{
-				Name:     lastFunctionCall.Name,
-				Response: apiContext, // Data from the backend service passed as context to the model
-			},
-		}},
+	functionMessage := &llm.Message{
+		Role: llm.RoleTool,
+		ToolResult: &llm.ToolResult{
+			ID:       lastFunctionCall.ID,
+			Name:     lastFunctionCall.Name,
+			Response: apiContext, // Data from the backend service passed as context to the model
+		},
 	}
-	contents = append(contents, functionContent)
+	messages = append(messages, functionMessage)
 
 	// Generate model response
-	resp, err := s.GenerateModelResponse(contents)
+	resp, err := s.GenerateModelResponse(ctx, phoneId, messages)
 	if err != nil {
-		s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-			Content:      &genai.Content{Role: "model", Parts: []*genai.Part{{Text: "Response generation error"}}},
+		// Nothing is recorded if the turn was superseded by a newer message
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return "", ctx.Err()
+		}
+
+		// The function result is still recorded, so the function call in the history is answered.
+		// The time budget of the turn may have run out, so the history is updated without it.
+		saveCtx := context.WithoutCancel(ctx)
+		s.UpdateChatHistory(saveCtx, phoneId, &dto.ConversationContext{
+			Message:      functionMessage,
+			CurrentState: currentState,
+		})
+		s.UpdateChatHistory(saveCtx, phoneId, &dto.ConversationContext{
+			Message:      &llm.Message{Role: llm.RoleModel, Text: "Response generation error"},
 			CurrentState: dto.StateResponseError,
 		})
 
-		log.Error().Err(err).Msg("Error generating response from Gemini API")
+		log.Error().Err(err).Msg("Error generating response from model")
 
-		return "Sorry, I am unable to process your request at the moment.", nil
+		return fallbackReply, nil
 	}
 
-	finalText := resp.Candidates[0].Content.Parts[0].Text
-
 	// Add details of function call to conversation history
-	s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-		Content:      functionContent,
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message:      functionMessage,
 		CurrentState: currentState,
 	})
 
-	// Add model's final response to conversation history
-	s.UpdateChatHistory(phoneId, &dto.ConversationContext{
-		Content:      &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{{Text: finalText}}},
+	// Determine the next conversation state if the model chained another function call
+	if resp.Message.ToolCall != nil {
+		applyDatePhrase(phoneId, latestUserInput(chatHistory), resp.Message.ToolCall)
+
+		currentState, err = s.GetNextStateAfterFunctionCall(resp.Message.ToolCall.Name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Add model's response to conversation history
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message:      resp.Message,
 		CurrentState: currentState,
 	})
 
-	return finalText, nil
+	return modelOutput(resp.Message), nil
+}
+
+// ExecutedCall is a function call that was executed in the current turn, along with its response
+type ExecutedCall struct {
+	Call     *llm.ToolCall
+	Response map[string]any
+}
+
+// RunAgentLoop executes the function calls made by the model and passes their results back to it,
+// until the model responds with text or a function call that must be handled outside the model.
+// If a function call was executed before the loop started, e.g. a search for nearby events, it is
+// passed as executed, so the reply of the model can be verified against its response.
+func (s *GeminiService) RunAgentLoop(ctx context.Context, phoneId string, modelResponse any, executed *ExecutedCall) (*AgentResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, agentTurnBudget)
+	defer cancel()
+
+	// Ticket tiers returned in this turn are rendered below the model's reply
+	var tiers []*dto.TicketTier
+
+	for step := 0; ; step++ {
+		switch v := modelResponse.(type) {
+		case string:
+			// Only replies to a function call executed in this turn are verified, against that call's response.
+			// Guard replies, fallback replies and replies of turns without a function call are sent as they are.
+			if executed == nil || v == fallbackReply {
+				return &AgentResult{Text: v}, nil
+			}
+
+			text, fromModel := s.verifyModelText(ctx, phoneId, v, executed.Call, executed.Response)
+			if fromModel && len(tiers) > 0 {
+				text = strings.TrimSpace(text + "\n\n" + render.TicketTiers(tiers, time.Now()))
+			}
+
+			return &AgentResult{Text: text}, nil
+		case *llm.ToolCall:
+			if ctx.Err() == context.Canceled {
+				return nil, ctx.Err()
+			}
+
+			if step >= maxAgentSteps || ctx.Err() != nil {
+				log.Warn().Str("function", v.Name).Int("steps", step).Msg("Agent loop stopped before model returned a text response")
+				return s.abandonFunctionCall(ctx, phoneId, v), nil
+			}
+
+			// Location of the user is required before nearby events can be fetched, unless a place was named.
+			// Invalid arguments are passed back to the model by the context service instead.
+			if v.Name == dto.FindNearbyEvents.String() {
+				if args, fnErr := s.context.decodeArgs(v); fnErr == nil && args.(*dto.FindNearbyEventsArgs).Place == "" {
+					return &AgentResult{Pending: v}, nil
+				}
+			}
+
+			// Retrieve data from backend service to be used as context
+			apiContext, err := s.context.SelectEndpoint(ctx, v, phoneId)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Warn().Err(err).Str("function", v.Name).Msg("Time budget of the turn ran out while executing function call")
+				return s.abandonFunctionCall(ctx, phoneId, v), nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			// Non-empty list of events are presented to the user for selection
+			if _, failed := apiContext["error"]; !failed && strings.HasPrefix(v.Name, "find_") {
+				events, ok := apiContext["events"].([]*dto.Event)
+				if !ok {
+					return nil, fmt.Errorf("Invalid payload type received from backend service")
+				}
+
+				if len(events) > 0 {
+					return &AgentResult{Pending: v, Response: apiContext}, nil
+				}
+			}
+
+			if v.Name == dto.SelectEvent.String() {
+				tiers, _ = apiContext["tickets"].([]*dto.TicketTier)
+			} else {
+				tiers = nil
+			}
+
+			log.Info().Str("function", v.Name).Int("step", step+1).Msg("Executed function call in agent loop")
+
+			executed = &ExecutedCall{Call: v, Response: apiContext}
+
+			modelResponse, err = s.ProcessFunctionCall(ctx, phoneId, apiContext)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Warn().Err(err).Str("function", v.Name).Msg("Time budget of the turn ran out while processing function result")
+				return s.abandonFunctionCall(ctx, phoneId, v), nil
+			} else if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Error processing model response: Unknown model response type")
+		}
+	}
+}
+
+// abandonFunctionCall answers a function call that could not be completed in the turn with an error, so
+// the call is not left without a result in the chat history, and records the fallback reply to the user
+func (s *GeminiService) abandonFunctionCall(ctx context.Context, phoneId string, call *llm.ToolCall) *AgentResult {
+	// The time budget of the turn may have run out, so the history is updated without it
+	ctx = context.WithoutCancel(ctx)
+
+	fnErr := &dto.FunctionError{Code: "NOT_EXECUTED", Message: "The function call could not be completed in time"}
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message: &llm.Message{
+			Role:       llm.RoleTool,
+			ToolResult: &llm.ToolResult{ID: call.ID, Name: call.Name, Response: fnErr.Response()},
+		},
+		CurrentState: dto.StateResponseError,
+	})
+	s.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+		Message:      &llm.Message{Role: llm.RoleModel, Text: fallbackReply},
+		CurrentState: dto.StateResponseError,
+	})
+
+	return &AgentResult{Text: fallbackReply}
+}
+
+// applyDatePhrase overrides the date filters of an event search with the dates parsed from the
+// latest message of the user, if the message contains a recognizable date phrase. Phrases that
+// are part of the event title, e.g. "New Year Party", are not treated as dates.
+func applyDatePhrase(phoneId, userInput string, call *llm.ToolCall) {
+	if call == nil || call.Name != dto.FindEvents.String() {
+		return
+	}
+
+	if title, _ := call.Args["eventTitle"].(string); strings.TrimSpace(title) != "" {
+		titlePattern := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(strings.TrimSpace(title)))
+		userInput = titlePattern.ReplaceAllString(userInput, " ")
+	}
+
+	dates, ok := dateparse.Parse(userInput, time.Now().In(util.UserLocation(phoneId)))
+	if !ok {
+		return
+	}
+
+	if call.Args == nil {
+		call.Args = map[string]any{}
+	}
+
+	startDate, _ := call.Args["startDate"].(string)
+	endDate, _ := call.Args["endDate"].(string)
+	if startDate != dates.StartDate() || endDate != dates.EndDate() {
+		log.Info().
+			Str("model_start", startDate).
+			Str("model_end", endDate).
+			Str("parsed_start", dates.StartDate()).
+			Str("parsed_end", dates.EndDate()).
+			Msg("Overriding date filters resolved by the model")
+	}
+
+	call.Args["startDate"] = dates.StartDate()
+	call.Args["endDate"] = dates.EndDate()
+}
+
+// PendingFunctionCall returns the last function call in the chat history if it has no result yet,
+// e.g. a search for nearby events that is waiting for the location of the user
+func (s *GeminiService) PendingFunctionCall(ctx context.Context, phoneId string) (*llm.ToolCall, error) {
+	chatHistory, err := s.GetChatHistory(ctx, phoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(chatHistory) - 1; i >= 0; i-- {
+		msg := chatHistory[i].Message
+		switch {
+		case msg.ToolCall != nil:
+			return msg.ToolCall, nil
+		case msg.ToolResult != nil, msg.Role == llm.RoleUser:
+			return nil, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// latestUserInput returns the text of the last message sent by the user
+func latestUserInput(chatHistory []dto.ConversationContext) string {
+	for i := len(chatHistory) - 1; i >= 0; i-- {
+		msg := chatHistory[i].Message
+		if msg.Role == llm.RoleUser && msg.ToolResult == nil && !chatHistory[i].Summary {
+			return msg.Text
+		}
+	}
+
+	return ""
 }
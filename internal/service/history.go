@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+	"google.golang.org/genai"
+)
+
+// Number of most recent messages that are never rolled up into a summary
+const recentHistoryWindow = 8
+
+const summaryInstructions = `
+You summarize conversations between Tejiri, a WhatsApp event ticketing assistant, and a user.
+Write a short plain-text summary of the conversation so far for the assistant to continue from.
+Keep every detail that affects the purchase flow: events found or selected (with their IDs and titles),
+ticket tiers and quantities chosen, email addresses provided, checkout links issued and payment outcomes.
+Leave out greetings, small talk and full event descriptions.
+`
+
+// BuildModelHistory converts the stored chat history into the messages sent to the model. Old function
+// responses are compressed, and older turns are rolled up into a summary once the token budget is exceeded.
+func (s *GeminiService) BuildModelHistory(ctx context.Context, phoneId string, chatHistory []dto.ConversationContext) []*llm.Message {
+	messages := compressHistory(chatHistory)
+
+	req := &llm.Request{
+		System:   s.prompts.SystemInstructions(),
+		Messages: messages,
+		Tools:    s.prompts.Tools(),
+	}
+
+	// Skip the token count request while the history is clearly within budget
+	tokens := llm.EstimateTokens(req)
+	if tokens > s.env.HistoryTokenBudget/2 {
+		count, err := llm.CountTokens(ctx, s.model, req)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error counting tokens of chat history. Using estimate")
+		} else {
+			tokens = count
+		}
+	}
+
+	if tokens <= s.env.HistoryTokenBudget {
+		return messages
+	}
+
+	split := rollupIndex(chatHistory)
+	if split <= 0 {
+		return messages
+	}
+
+	summary := &dto.ConversationContext{
+		Message: &llm.Message{
+			Role: llm.RoleUser,
+			Text: "Summary of the earlier conversation:\n" + s.summarizeMessages(ctx, messages[:split]),
+		},
+		CurrentState:  chatHistory[split-1].CurrentState,
+		Summary:       true,
+		PromptVersion: s.prompts.Version(),
+	}
+
+	// Persist the rolled up history so older turns are not summarized again
+	if err := s.trimChatHistory(ctx, phoneId, chatHistory[:split], summary); errors.Is(err, errHistoryChanged) {
+		log.Warn().Msg("Chat history changed while it was summarized. Summary not stored")
+	} else if err != nil {
+		log.Error().Err(err).Msg("Error storing summarized chat history")
+	}
+
+	log.Info().Int("tokens", tokens).Int("summarized_messages", split).Msg("Chat history rolled up into summary")
+
+	return append([]*llm.Message{summary.Message}, messages[split:]...)
+}
+
+// Attempts to store a summary before giving up, if messages keep being appended to the history meanwhile
+const maxTrimAttempts = 3
+
+var errHistoryChanged = errors.New("Summarized messages no longer match the stored chat history")
+
+// trimChatHistory replaces the summarized messages at the start of the stored chat history with their summary.
+// Messages appended since the history was read are kept, and nothing is changed if the summarized messages
+// were modified, e.g. by another summary.
+func (s *GeminiService) trimChatHistory(ctx context.Context, phoneId string, summarized []dto.ConversationContext, summary *dto.ConversationContext) error {
+	cacheKey := "chat_history:" + util.CreateHashedKey(phoneId)
+	expected, _ := json.Marshal(summarized)
+
+	trim := func(tx *redis.Tx) error {
+		items, err := tx.LRange(ctx, cacheKey, 0, int64(len(summarized))-1).Result()
+		if err != nil {
+			return err
+		}
+
+		stored := make([]dto.ConversationContext, 0, len(items))
+		for _, item := range items {
+			contextObj, _, err := decodeChatContext(item)
+			if err != nil || contextObj.Message == nil {
+				return errHistoryChanged
+			}
+			stored = append(stored, contextObj)
+		}
+
+		if current, _ := json.Marshal(stored); !bytes.Equal(current, expected) {
+			return errHistoryChanged
+		}
+
+		// Applied only if the history is not modified after it is watched
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.LTrim(ctx, cacheKey, int64(len(summarized)), -1)
+			pipe.LPush(ctx, cacheKey, summary)
+			pipe.Expire(ctx, cacheKey, time.Hour*6)
+
+			return nil
+		})
+
+		return err
+	}
+
+	for attempt := 0; attempt < maxTrimAttempts; attempt++ {
+		err := s.cache.Watch(ctx, trim, cacheKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("Error trimming chat history: History kept changing")
+}
+
+// storedContext is a conversation context as stored in the chat history. Contexts stored before
+// messages were provider independent hold the message in the Gemini format.
+type storedContext struct {
+	dto.ConversationContext
+	Content *genai.Content `json:"content,omitempty"`
+}
+
+// decodeChatContext decodes a context stored in the chat history, converting it from the Gemini format
+// if needed, and reports whether it was converted. The message of the returned context is nil if the stored
+// context holds no message.
+func decodeChatContext(item string) (dto.ConversationContext, bool, error) {
+	var stored storedContext
+	if err := json.Unmarshal([]byte(item), &stored); err != nil {
+		return dto.ConversationContext{}, false, err
+	}
+
+	if stored.Message != nil || stored.Content == nil {
+		return stored.ConversationContext, false, nil
+	}
+
+	stored.Message = llm.FromGeminiContent(stored.Content)
+	return stored.ConversationContext, stored.Message != nil, nil
+}
+
+func (s *GeminiService) summarizeMessages(ctx context.Context, messages []*llm.Message) string {
+	transcript := renderTranscript(messages)
+
+	resp, err := s.model.Generate(ctx, &llm.Request{
+		System:   summaryInstructions,
+		Messages: []*llm.Message{{Role: llm.RoleUser, Text: transcript}},
+	})
+	if err == nil && strings.TrimSpace(resp.Message.Text) != "" {
+		return strings.TrimSpace(resp.Message.Text)
+	}
+
+	log.Warn().Err(err).Msg("Error summarizing chat history. Using transcript excerpt")
+
+	// Keep the most recent part of the transcript if the model is unavailable
+	const maxExcerpt = 2000
+	if len(transcript) > maxExcerpt {
+		transcript = "..." + transcript[len(transcript)-maxExcerpt:]
+	}
+
+	return transcript
+}
+
+// rollupIndex returns the index of the first message kept in full. The kept messages always
+// start at a user message so function calls are never separated from their results.
+func rollupIndex(chatHistory []dto.ConversationContext) int {
+	for i := len(chatHistory) - recentHistoryWindow; i > 0; i-- {
+		m := chatHistory[i].Message
+		if m.Role == llm.RoleUser && m.ToolResult == nil && !chatHistory[i].Summary {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// compressHistory shortens every function response except the most recent one
+func compressHistory(chatHistory []dto.ConversationContext) []*llm.Message {
+	lastResult := -1
+	for i := len(chatHistory) - 1; i >= 0; i-- {
+		if chatHistory[i].Message.ToolResult != nil {
+			lastResult = i
+			break
+		}
+	}
+
+	messages := make([]*llm.Message, 0, len(chatHistory))
+	for i, h := range chatHistory {
+		if h.Message.ToolResult == nil || i == lastResult {
+			messages = append(messages, h.Message)
+			continue
+		}
+
+		compressed := *h.Message
+		compressed.ToolResult = &llm.ToolResult{
+			ID:       h.Message.ToolResult.ID,
+			Name:     h.Message.ToolResult.Name,
+			Response: compressFunctionResponse(h.Message.ToolResult.Response),
+		}
+		messages = append(messages, &compressed)
+	}
+
+	return messages
+}
+
+// compressFunctionResponse keeps only the fields needed to refer back to events and ticket tiers
+func compressFunctionResponse(response map[string]any) map[string]any {
+	keep := map[string][]string{
+		"events":  {"id", "title"},
+		"tickets": {"name", "soldOut"},
+	}
+
+	compressed := make(map[string]any, len(response))
+	for key, value := range response {
+		fields, ok := keep[key]
+		if !ok {
+			compressed[key] = value
+			continue
+		}
+
+		// Normalize typed backend payloads and payloads decoded from cache
+		var items []map[string]any
+		raw, _ := json.Marshal(value)
+		if err := json.Unmarshal(raw, &items); err != nil {
+			compressed[key] = value
+			continue
+		}
+
+		summaries := make([]map[string]any, 0, len(items))
+		for _, item := range items {
+			summary := make(map[string]any, len(fields))
+			for _, f := range fields {
+				summary[f] = item[f]
+			}
+			summaries = append(summaries, summary)
+		}
+		compressed[key] = summaries
+	}
+
+	return compressed
+}
+
+func renderTranscript(messages []*llm.Message) string {
+	var b strings.Builder
+
+	for _, m := range messages {
+		switch {
+		case m.ToolCall != nil:
+			args, _ := json.Marshal(m.ToolCall.Args)
+			fmt.Fprintf(&b, "Assistant called %s with %s\n", m.ToolCall.Name, args)
+		case m.ToolResult != nil:
+			response, _ := json.Marshal(m.ToolResult.Response)
+			fmt.Fprintf(&b, "Result of %s: %s\n", m.ToolResult.Name, response)
+		case m.Role == llm.RoleModel:
+			fmt.Fprintf(&b, "Assistant: %s\n", m.Text)
+		default:
+			fmt.Fprintf(&b, "User: %s\n", m.Text)
+		}
+	}
+
+	return b.String()
+}
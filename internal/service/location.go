@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/geo"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+)
+
+const (
+	// Saved locations newer than this are reused without asking the user
+	locationReuseWindow = 15 * time.Minute
+	// Locations shared before the user answers the consent question are kept for this long
+	pendingLocationTTL = 15 * time.Minute
+	// The user is asked for consent again after this long
+	locationConsentTTL = 30 * 24 * time.Hour
+
+	consentGranted = "granted"
+	consentDenied  = "denied"
+)
+
+// IDs of the location buttons, which are routed by their prefix instead of being passed to the model
+const (
+	locationButtonPrefix   = "location:"
+	reuseLocationButton    = "location:reuse"
+	newLocationButton      = "location:new"
+	rememberLocationButton = "location:remember"
+	forgetLocationButton   = "location:forget"
+)
+
+type savedLocation struct {
+	geo.Point
+	Name    string    `json:"name,omitempty"`
+	Address string    `json:"address,omitempty"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+func (l savedLocation) MarshalBinary() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// label describes the location to the user by its name, address or coordinates
+func (l savedLocation) label() string {
+	switch {
+	case l.Name != "" && l.Address != "":
+		return l.Name + ", " + l.Address
+	case l.Name != "":
+		return l.Name
+	case l.Address != "":
+		return l.Address
+	default:
+		return fmt.Sprintf("%.4f, %.4f", l.Latitude, l.Longitude)
+	}
+}
+
+func (s *MessageService) handleLocationMessage(ctx context.Context, senderId, messageId string, message *dto.LocationMessage) error {
+	location := savedLocation{
+		Point:   geo.Point{Latitude: message.Latitude, Longitude: message.Longitude},
+		SavedAt: time.Now(),
+	}
+	if message.Name != nil {
+		location.Name = *message.Name
+	}
+	if message.Address != nil {
+		location.Address = *message.Address
+	}
+
+	if err := s.searchNearby(ctx, senderId, messageId, location.Point); err != nil {
+		return err
+	}
+
+	consent, err := s.cache.Get(ctx, "location_consent:"+util.CreateHashedKey(senderId)).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("Error fetching location consent from cache: %s", err.Error())
+	}
+
+	switch consent {
+	case consentGranted:
+		return s.saveLocation(ctx, senderId, location)
+	case consentDenied:
+		return nil
+	}
+
+	// Hold the location until the user decides whether it should be remembered
+	cacheKey := "pending_location:" + util.CreateHashedKey(senderId)
+	if err := s.cache.Set(ctx, cacheKey, location, pendingLocationTTL).Err(); err != nil {
+		return fmt.Errorf("Error storing pending location in cache: %s", err.Error())
+	}
+
+	text := fmt.Sprintf("Should I remember this location for the next %d hours, so you don't have to share it again for nearby searches?", s.env.LocationTTL)
+	return s.sendReplyButtons(ctx, senderId, messageId, text,
+		replyButton(rememberLocationButton, "Yes, remember it"),
+		replyButton(forgetLocationButton, "No, thanks"),
+	)
+}
+
+// requestLocation searches near the saved location of the user if it was shared recently. An older saved
+// location is offered to the user for reuse, and a location request is sent if there is none.
+func (s *MessageService) requestLocation(ctx context.Context, phoneId, messageId string) error {
+	location, err := s.getSavedLocation(ctx, phoneId)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error fetching saved location")
+	}
+
+	if location == nil {
+		return s.sendLocationRequest(ctx, phoneId, messageId)
+	}
+
+	if time.Since(location.SavedAt) < locationReuseWindow {
+		return s.searchNearby(ctx, phoneId, messageId, location.Point)
+	}
+
+	text := fmt.Sprintf("Do you want me to search near the same location as before?\n\n%s", location.label())
+	return s.sendReplyButtons(ctx, phoneId, messageId, text,
+		replyButton(reuseLocationButton, "Same location"),
+		replyButton(newLocationButton, "New location"),
+	)
+}
+
+func (s *MessageService) handleLocationButton(ctx context.Context, senderId, messageId, buttonId string) error {
+	hashedKey := util.CreateHashedKey(senderId)
+	errorMsg := "Error handling location button reply from Whatsapp Cloud API"
+
+	switch buttonId {
+	case reuseLocationButton:
+		location, err := s.getSavedLocation(ctx, senderId)
+		if err != nil {
+			return err
+		}
+
+		// The saved location may have expired since the buttons were sent
+		if location == nil {
+			return s.sendLocationRequest(ctx, senderId, messageId)
+		}
+
+		return s.searchNearby(ctx, senderId, messageId, location.Point)
+	case newLocationButton:
+		return s.sendLocationRequest(ctx, senderId, messageId)
+	case rememberLocationButton:
+		if err := s.cache.Set(ctx, "location_consent:"+hashedKey, consentGranted, locationConsentTTL).Err(); err != nil {
+			return fmt.Errorf("Error storing location consent in cache: %s", err.Error())
+		}
+
+		result, err := s.cache.GetDel(ctx, "pending_location:"+hashedKey).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("Error fetching pending location from cache: %s", err.Error())
+		}
+
+		if result != "" {
+			var location savedLocation
+			if err := json.Unmarshal([]byte(result), &location); err != nil {
+				return fmt.Errorf("Error parsing pending location stored in cache: %s", err.Error())
+			}
+
+			if err := s.saveLocation(ctx, senderId, location); err != nil {
+				return err
+			}
+		}
+
+		text := fmt.Sprintf("Done! I'll use this location for nearby searches over the next %d hours.", s.env.LocationTTL)
+		return s.sendTextReply(ctx, senderId, messageId, text, errorMsg)
+	case forgetLocationButton:
+		if err := s.cache.Set(ctx, "location_consent:"+hashedKey, consentDenied, locationConsentTTL).Err(); err != nil {
+			return fmt.Errorf("Error storing location consent in cache: %s", err.Error())
+		}
+
+		if err := s.cache.Del(ctx, "pending_location:"+hashedKey, "last_location:"+hashedKey).Err(); err != nil {
+			return fmt.Errorf("Error deleting location from cache: %s", err.Error())
+		}
+
+		text := "No problem. I won't store your location, and I'll ask for it whenever you search for nearby events."
+		return s.sendTextReply(ctx, senderId, messageId, text, errorMsg)
+	default:
+		return fmt.Errorf("Invalid location button reply received from Whatsapp Cloud API: %s", buttonId)
+	}
+}
+
+func (s *MessageService) saveLocation(ctx context.Context, phoneId string, location savedLocation) error {
+	cacheKey := "last_location:" + util.CreateHashedKey(phoneId)
+	ttl := time.Duration(s.env.LocationTTL) * time.Hour
+
+	if err := s.cache.Set(ctx, cacheKey, location, ttl).Err(); err != nil {
+		return fmt.Errorf("Error storing location in cache: %s", err.Error())
+	}
+
+	return nil
+}
+
+// getSavedLocation returns the last location shared by the user, or nil if there is none
+func (s *MessageService) getSavedLocation(ctx context.Context, phoneId string) (*savedLocation, error) {
+	result, err := s.cache.Get(ctx, "last_location:"+util.CreateHashedKey(phoneId)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error fetching saved location from cache: %s", err.Error())
+	}
+
+	var location savedLocation
+	if err := json.Unmarshal([]byte(result), &location); err != nil {
+		return nil, fmt.Errorf("Error parsing saved location stored in cache: %s", err.Error())
+	}
+
+	return &location, nil
+}
+
+// searchNearby sends the events near a location to the user, or passes the empty result to the model
+func (s *MessageService) searchNearby(ctx context.Context, senderId, messageId string, origin geo.Point) error {
+	radiusKm := s.nearbySearchRadius(ctx, senderId)
+
+	// The search answers the function call waiting for the location of the user, if there is one
+	call, err := s.gemini.PendingFunctionCall(ctx, senderId)
+	if err != nil {
+		return err
+	}
+
+	apiContext, err := s.context.GetNearbyEvents(ctx, origin, radiusKm)
+	if fnErr := functionError(err); fnErr != nil {
+		// Let the model explain the failed search to the user
+		log.Warn().Err(err).Msg("Backend request failed. Passing error to model")
+		apiContext = fnErr.Response()
+	} else if err != nil {
+		return err
+	}
+
+	events, _ := apiContext["events"].([]*dto.Event)
+
+	// Send list of nearby events to user
+	if len(events) > 0 {
+		funcName := dto.FindNearbyEvents.String()
+
+		// Results of searches started without a function call are recorded without a call ID
+		var callId string
+		if call != nil {
+			callId = call.ID
+		}
+
+		return s.sendEventsList(ctx, senderId, messageId, funcName, callId, apiContext, events)
+	}
+
+	// Update function call with empty or failed result
+	resp, err := s.gemini.ProcessFunctionCall(ctx, senderId, apiContext)
+	if err != nil {
+		return err
+	}
+
+	// The reply of the model is verified against the search, if the search was requested by the model
+	var executed *ExecutedCall
+	if call != nil {
+		executed = &ExecutedCall{Call: call, Response: apiContext}
+	}
+
+	result, err := s.gemini.RunAgentLoop(ctx, senderId, resp, executed)
+	if err != nil {
+		return err
+	}
+
+	// Searching the same location again would return the same empty result
+	if result.Pending != nil && result.Pending.Name == dto.FindNearbyEvents.String() {
+		return s.sendLocationRequest(ctx, senderId, messageId)
+	}
+
+	errorMsg := "Error handling location message webhook from Whatsapp Cloud API"
+	return s.deliverAgentResult(ctx, senderId, messageId, result, errorMsg)
+}
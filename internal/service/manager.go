@@ -6,11 +6,15 @@ import (
 )
 
 type Manager struct {
+	Context *ContextService
 	Message *MessageService
 }
 
 func NewManager(s *secrets.Secrets, r *redis.Client) *Manager {
+	contextService := NewContextService(s, r)
+
 	return &Manager{
-		Message: NewMessageService(s, r),
+		Context: contextService,
+		Message: NewMessageService(s, r, contextService),
 	}
 }
@@ -2,33 +2,45 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/geo"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
 	"github.com/xerdin442/ticketing-bot/internal/secrets"
 	"github.com/xerdin442/ticketing-bot/internal/util"
-	"google.golang.org/genai"
 )
 
 type MessageService struct {
 	env        *secrets.Secrets
+	cache      *redis.Client
 	context    *ContextService
 	gemini     *GeminiService
 	httpClient *http.Client
+	inflight   sync.Map // Turns currently being processed, keyed by phone ID
 }
 
-func NewMessageService(s *secrets.Secrets, r *redis.Client) *MessageService {
+type turn struct {
+	cancel context.CancelFunc
+}
+
+func NewMessageService(s *secrets.Secrets, r *redis.Client, contextService *ContextService) *MessageService {
 	return &MessageService{
 		env:        s,
-		context:    NewContextService(s, r),
-		gemini:     NewGeminiService(s, r),
+		cache:      r,
+		context:    contextService,
+		gemini:     NewGeminiService(s, r, contextService),
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 	}
 }
@@ -37,9 +49,9 @@ func ptr(s string) *string {
 	return &s
 }
 
-func (s *MessageService) sendRequest(body io.Reader, errorMsg string) error {
+func (s *MessageService) sendRequest(ctx context.Context, body io.Reader, errorMsg string) error {
 	// Configure request details
-	req, err := http.NewRequest("POST", s.env.WhatsappMessagingApiUrl, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.env.WhatsappMessagingApiUrl, body)
 	if err != nil {
 		return fmt.Errorf("Error configuring new HTTP request. %s", err.Error())
 	}
@@ -62,7 +74,7 @@ func (s *MessageService) sendRequest(body io.Reader, errorMsg string) error {
 	return nil
 }
 
-func (s *MessageService) markMessageAsRead(messageId string) error {
+func (s *MessageService) markMessageAsRead(ctx context.Context, messageId string) error {
 	// Configure request payload
 	payload := dto.MessageRequestPayload{
 		MessagingProduct: "whatsapp",
@@ -74,10 +86,10 @@ func (s *MessageService) markMessageAsRead(messageId string) error {
 	}
 
 	body, _ := json.Marshal(payload)
-	return s.sendRequest(bytes.NewBuffer(body), "Error marking message as read")
+	return s.sendRequest(ctx, bytes.NewBuffer(body), "Error marking message as read")
 }
 
-func (s *MessageService) sendLocationRequest(phoneId, messageId string) error {
+func (s *MessageService) sendLocationRequest(ctx context.Context, phoneId, messageId string) error {
 	// Configure request payload
 	payload := dto.MessageRequestPayload{
 		MessagingProduct: "whatsapp",
@@ -101,15 +113,24 @@ func (s *MessageService) sendLocationRequest(phoneId, messageId string) error {
 	}
 
 	// Mark previous message as read
-	if err := s.markMessageAsRead(messageId); err != nil {
+	if err := s.markMessageAsRead(ctx, messageId); err != nil {
 		return err
 	}
 
 	body, _ := json.Marshal(payload)
-	return s.sendRequest(bytes.NewBuffer(body), "Error sending location request message")
+	return s.sendRequest(ctx, bytes.NewBuffer(body), "Error sending location request message")
+}
+
+func eventCaption(event *dto.Event) string {
+	caption := fmt.Sprintf("%v\n\nDate: %v", strings.ToUpper(event.Title), util.FormatDate(event.Date))
+	if event.Distance != nil {
+		caption += fmt.Sprintf("\nDistance: %s away", geo.FormatDistance(*event.Distance))
+	}
+
+	return caption
 }
 
-func (s *MessageService) sendInteractiveBtnMessage(phoneId string, event *dto.Event) error {
+func (s *MessageService) sendInteractiveBtnMessage(ctx context.Context, phoneId string, event *dto.Event) error {
 	// Configure request payload
 	payload := dto.MessageRequestPayload{
 		MessagingProduct: "whatsapp",
@@ -129,7 +150,7 @@ func (s *MessageService) sendInteractiveBtnMessage(phoneId string, event *dto.Ev
 			Body: struct {
 				Text string "json:\"text\""
 			}{
-				Text: fmt.Sprintf("%v\n\nDate: %v", strings.ToUpper(event.Title), util.FormatDate(event.Date)),
+				Text: eventCaption(event),
 			},
 			Action: dto.ReplyInteractiveAction{
 				Buttons: []dto.ReplyInteractiveButton{
@@ -149,34 +170,33 @@ func (s *MessageService) sendInteractiveBtnMessage(phoneId string, event *dto.Ev
 	}
 
 	body, _ := json.Marshal(payload)
-	return s.sendRequest(bytes.NewBuffer(body), "Error sending interactive button message")
+	return s.sendRequest(ctx, bytes.NewBuffer(body), "Error sending interactive button message")
 }
 
-func (s *MessageService) sendEventsList(phoneId, messageId, funcName string, ctx map[string]any, events []*dto.Event) error {
+func (s *MessageService) sendEventsList(ctx context.Context, phoneId, messageId, funcName, callId string, apiContext map[string]any, events []*dto.Event) error {
 	functionResult := &dto.ConversationContext{
-		Content: &genai.Content{
-			Role: "system",
-			Parts: []*genai.Part{{
-				FunctionResponse: &genai.FunctionResponse{
-					Name:     funcName,
-					Response: ctx,
-				},
-			}},
+		Message: &llm.Message{
+			Role: llm.RoleTool,
+			ToolResult: &llm.ToolResult{
+				ID:       callId,
+				Name:     funcName,
+				Response: apiContext,
+			},
 		},
 		CurrentState: dto.StateEventQuery,
 	}
 
 	// Add function result to conversation history
-	s.gemini.UpdateChatHistory(phoneId, functionResult)
+	s.gemini.UpdateChatHistory(ctx, phoneId, functionResult)
 
 	// Mark previous message as read
-	if err := s.markMessageAsRead(messageId); err != nil {
+	if err := s.markMessageAsRead(ctx, messageId); err != nil {
 		return err
 	}
 
 	// Send list of events (trending or filter search results) to user
 	for _, e := range events {
-		if err := s.sendInteractiveBtnMessage(phoneId, e); err != nil {
+		if err := s.sendInteractiveBtnMessage(ctx, phoneId, e); err != nil {
 			log.Error().Err(err).Msgf("Failed to send event with ID: %d", e.ID)
 			continue
 		}
@@ -188,150 +208,161 @@ func (s *MessageService) sendEventsList(phoneId, messageId, funcName string, ctx
 	return nil
 }
 
-func (s *MessageService) HandleIncomingMessage(message dto.IncomingMessage) error {
-	senderId := message.From
-	messageId := message.ID
+func replyButton(id, title string) dto.ReplyInteractiveButton {
+	button := dto.ReplyInteractiveButton{Type: "reply"}
+	button.Reply.ID = id
+	button.Reply.Title = title
 
-	switch message.Type {
-	case dto.TextMessageType:
-		var finalResponse string
+	return button
+}
 
-		// Process incoming message from user
-		userInput := message.Text.Body
-		firstResponse, err := s.gemini.ProcessUserMessage(senderId, userInput)
-		if err != nil {
-			return err
-		}
+func (s *MessageService) sendReplyButtons(ctx context.Context, phoneId, messageId, text string, buttons ...dto.ReplyInteractiveButton) error {
+	// Configure request payload
+	payload := dto.MessageRequestPayload{
+		MessagingProduct: "whatsapp",
+		RecipientType:    ptr("individual"),
+		To:               &phoneId,
+		Type:             ptr("interactive"),
+		Interactive: &dto.ReplyInteractive{
+			Type: dto.ButtonInteractiveReply,
+			Body: struct {
+				Text string "json:\"text\""
+			}{Text: text},
+			Action: dto.ReplyInteractiveAction{Buttons: buttons},
+		},
+	}
 
-		switch v := firstResponse.(type) {
-		case string:
-			// Model responds directly with text (initial welcome message or follow-up question)
-			text, _ := firstResponse.(string)
-			finalResponse = text
-		case *genai.FunctionCall:
-			// Model makes a function call (requires context from backend service)
-			functionCall := v
-
-			// Retrieve data from backend service to be used as context
-			if functionCall.Name == dto.FindNearbyEvents.String() {
-				// Send a location request to the user to get coordinates
-				s.sendLocationRequest(senderId, messageId)
-				return nil
-			}
+	// Mark previous message as read
+	if err := s.markMessageAsRead(ctx, messageId); err != nil {
+		return err
+	}
 
-			apiContext, err := s.context.SelectEndpoint(functionCall, senderId)
-			if err != nil {
-				return err
-			}
+	body, _ := json.Marshal(payload)
+	return s.sendRequest(ctx, bytes.NewBuffer(body), "Error sending reply buttons message")
+}
 
-			if strings.HasPrefix(functionCall.Name, "find_") {
-				events, ok := apiContext["events"].([]*dto.Event)
-				if !ok {
-					return fmt.Errorf("Invalid payload type received from backend service")
-				}
+func (s *MessageService) sendTextReply(ctx context.Context, phoneId, messageId, text, errorMsg string) error {
+	// Configure request payload
+	payload := dto.MessageRequestPayload{
+		MessagingProduct: "whatsapp",
+		RecipientType:    ptr("individual"),
+		To:               &phoneId,
+		Type:             ptr("text"),
+		Context: &struct {
+			MessageID string "json:\"message_id\""
+		}{MessageID: messageId},
+		Text: &dto.ReplyText{
+			PreviewURL: true,
+			Body:       text,
+		},
+	}
 
-				// Send interactive buttton messages for users to select from if context is a non-empty list of events
-				if len(events) > 0 {
-					if err := s.sendEventsList(senderId, messageId, functionCall.Name, apiContext, events); err != nil {
-						return err
-					}
+	// Mark previous message as read
+	if err := s.markMessageAsRead(ctx, messageId); err != nil {
+		return err
+	}
 
-					return nil
-				}
+	body, _ := json.Marshal(payload)
+	return s.sendRequest(ctx, bytes.NewBuffer(body), errorMsg)
+}
 
-				// Update function call with empty events search result
-				secondResponse, err := s.gemini.ProcessFunctionCall(senderId, apiContext)
-				if err != nil {
-					return err
-				}
+func (s *MessageService) deliverAgentResult(ctx context.Context, phoneId, messageId string, result *AgentResult, errorMsg string) error {
+	if result.Pending == nil {
+		return s.sendTextReply(ctx, phoneId, messageId, result.Text, errorMsg)
+	}
 
-				finalResponse = secondResponse
-			}
-		default:
-			return fmt.Errorf("Error processing user input: Unknown model response type")
-		}
+	// Reuse the saved location of the user, or send a location request to get coordinates
+	if result.Pending.Name == dto.FindNearbyEvents.String() && result.Response == nil {
+		return s.requestLocation(ctx, phoneId, messageId)
+	}
 
-		// Configure request payload
-		payload := dto.MessageRequestPayload{
-			MessagingProduct: "whatsapp",
-			RecipientType:    ptr("individual"),
-			To:               &senderId,
-			Type:             ptr("text"),
-			Context: &struct {
-				MessageID string "json:\"message_id\""
-			}{MessageID: messageId},
-			Text: &dto.ReplyText{
-				PreviewURL: true,
-				Body:       finalResponse,
-			},
-		}
+	// Send interactive buttton messages for users to select from
+	events, _ := result.Response["events"].([]*dto.Event)
+	return s.sendEventsList(ctx, phoneId, messageId, result.Pending.Name, result.Pending.ID, result.Response, events)
+}
 
-		// Mark previous message as read
-		if err := s.markMessageAsRead(messageId); err != nil {
-			return err
-		}
+// HandleIncomingMessage processes a message from the user. A turn that is still being processed
+// for the same user is cancelled, since its reply would be superseded by the newer message.
+func (s *MessageService) HandleIncomingMessage(ctx context.Context, message dto.IncomingMessage) error {
+	s.openServiceWindow(ctx, message)
 
-		body, _ := json.Marshal(payload)
-		errorMsg := "Error handling text message webhook from Whatsapp Cloud API"
-		return s.sendRequest(bytes.NewBuffer(body), errorMsg)
-	case dto.LocationMessageType:
-		// Extract coordinates from location message
-		latitude := message.Location.Latitude
-		longitude := message.Location.Longitude
+	ctx, done := s.startTurn(ctx, message.From)
+	defer done()
 
-		apiContext, err := s.context.GetNearbyEvents(int(latitude), int(longitude))
-		if err != nil {
-			return err
-		}
+	err := s.handleMessage(ctx, message)
+	if errors.Is(err, context.Canceled) {
+		log.Info().Str("message_id", message.ID).Msg("Message processing cancelled by a newer message")
+		return nil
+	}
 
-		events, ok := apiContext["events"].([]*dto.Event)
-		if !ok {
-			return fmt.Errorf("Invalid payload type received from backend service")
-		}
+	return err
+}
 
-		// Send list of nearby events to user
-		if len(events) > 0 {
-			funcName := dto.FindNearbyEvents.String()
-			if err := s.sendEventsList(senderId, messageId, funcName, apiContext, events); err != nil {
-				return err
-			}
+// openServiceWindow records that the user can receive free-form messages for 24 hours after their message.
+// Messages sent outside this window, e.g. event notices, must use an approved template instead.
+func (s *MessageService) openServiceWindow(ctx context.Context, message dto.IncomingMessage) {
+	sentAt := time.Now()
+	if ts, err := strconv.ParseInt(message.Timestamp, 10, 64); err == nil {
+		sentAt = time.Unix(ts, 0)
+	}
 
-			return nil
-		}
+	ttl := time.Until(sentAt.Add(24 * time.Hour))
+	if ttl <= 0 {
+		return
+	}
+
+	cacheKey := "service_window:" + util.CreateHashedKey(message.From)
+	if err := s.cache.Set(ctx, cacheKey, sentAt.Unix(), ttl).Err(); err != nil {
+		log.Warn().Err(err).Msg("Error storing customer service window in cache")
+	}
+}
+
+func (s *MessageService) startTurn(ctx context.Context, phoneId string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	current := &turn{cancel: cancel}
+
+	if previous, ok := s.inflight.Swap(phoneId, current); ok {
+		previous.(*turn).cancel()
+	}
+
+	return ctx, func() {
+		s.inflight.CompareAndDelete(phoneId, current)
+		cancel()
+	}
+}
+
+func (s *MessageService) handleMessage(ctx context.Context, message dto.IncomingMessage) error {
+	senderId := message.From
+	messageId := message.ID
 
-		// Update function call with empty result
-		resp, err := s.gemini.ProcessFunctionCall(senderId, apiContext)
+	switch message.Type {
+	case dto.TextMessageType:
+		// Process incoming message from user
+		userInput := message.Text.Body
+		firstResponse, err := s.gemini.ProcessUserMessage(ctx, senderId, userInput)
 		if err != nil {
 			return err
 		}
 
-		// Configure request payload
-		payload := dto.MessageRequestPayload{
-			MessagingProduct: "whatsapp",
-			RecipientType:    ptr("individual"),
-			To:               &senderId,
-			Type:             ptr("text"),
-			Context: &struct {
-				MessageID string "json:\"message_id\""
-			}{MessageID: messageId},
-			Text: &dto.ReplyText{
-				PreviewURL: true,
-				Body:       resp,
-			},
-		}
-
-		// Mark previous message as read
-		if err := s.markMessageAsRead(messageId); err != nil {
+		// Execute function calls made by the model until a reply is ready for the user
+		result, err := s.gemini.RunAgentLoop(ctx, senderId, firstResponse, nil)
+		if err != nil {
 			return err
 		}
 
-		body, _ := json.Marshal(payload)
-		errorMsg := "Error handling location message webhook from Whatsapp Cloud API"
-		return s.sendRequest(bytes.NewBuffer(body), errorMsg)
+		errorMsg := "Error handling text message webhook from Whatsapp Cloud API"
+		return s.deliverAgentResult(ctx, senderId, messageId, result, errorMsg)
+	case dto.LocationMessageType:
+		return s.handleLocationMessage(ctx, senderId, messageId, message.Location)
 	case dto.InteractiveMessageType:
-		// Extract details of user's selection and pass as context to model
+		// Location buttons are handled without the model
 		userInput := message.Interactive.ButtonReply.ID
-		firstResponse, err := s.gemini.ProcessUserMessage(senderId, userInput)
+		if strings.HasPrefix(userInput, locationButtonPrefix) {
+			return s.handleLocationButton(ctx, senderId, messageId, userInput)
+		}
+
+		// Extract details of user's selection and pass as context to model
+		firstResponse, err := s.gemini.ProcessUserMessage(ctx, senderId, userInput)
 		if err != nil {
 			return err
 		}
@@ -340,46 +371,19 @@ func (s *MessageService) HandleIncomingMessage(message dto.IncomingMessage) erro
 		switch v := firstResponse.(type) {
 		case string:
 			return fmt.Errorf("Incorrect model response. Expected a function call")
-		case *genai.FunctionCall:
+		case *llm.ToolCall:
 			// Verify details of function call
 			if v.Name != dto.SelectEvent.String() {
 				return fmt.Errorf("Error verifying function call from model. Expected %s, received: %s", dto.SelectEvent, v.Name)
 			}
 
-			apiContext, err := s.context.SelectEndpoint(v, senderId)
-			if err != nil {
-				return err
-			}
-
-			// Process function call
-			resp, err := s.gemini.ProcessFunctionCall(senderId, apiContext)
+			result, err := s.gemini.RunAgentLoop(ctx, senderId, v, nil)
 			if err != nil {
 				return err
 			}
 
-			// Configure request payload
-			payload := dto.MessageRequestPayload{
-				MessagingProduct: "whatsapp",
-				RecipientType:    ptr("individual"),
-				To:               &senderId,
-				Type:             ptr("text"),
-				Context: &struct {
-					MessageID string "json:\"message_id\""
-				}{MessageID: messageId},
-				Text: &dto.ReplyText{
-					PreviewURL: true,
-					Body:       resp,
-				},
-			}
-
-			// Mark previous message as read
-			if err := s.markMessageAsRead(messageId); err != nil {
-				return err
-			}
-
-			body, _ := json.Marshal(payload)
 			errorMsg := "Error handling interactive message webhook from Whatsapp Cloud API"
-			return s.sendRequest(bytes.NewBuffer(body), errorMsg)
+			return s.deliverAgentResult(ctx, senderId, messageId, result, errorMsg)
 		default:
 		}
 
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/geo"
+)
+
+// sortByDistance orders events from the nearest to the farthest. Distances missing from the backend
+// response are computed from the coordinates of the event, and events outside the radius are dropped.
+// Events without a known distance are kept at the end of the list.
+func sortByDistance(origin geo.Point, radiusKm float64, events []*dto.Event) []*dto.Event {
+	sorted := make([]*dto.Event, 0, len(events))
+
+	for _, e := range events {
+		if e.Distance == nil && e.Latitude != nil && e.Longitude != nil {
+			distance := geo.Distance(origin, geo.Point{Latitude: *e.Latitude, Longitude: *e.Longitude})
+			e.Distance = &distance
+		}
+
+		if e.Distance != nil && *e.Distance > radiusKm {
+			continue
+		}
+
+		sorted = append(sorted, e)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Distance, sorted[j].Distance
+		if a == nil || b == nil {
+			return a != nil
+		}
+
+		return *a < *b
+	})
+
+	return sorted
+}
+
+// nearbySearchRadius returns the radius requested in the latest nearby search of the user, or zero for the default radius
+func (s *MessageService) nearbySearchRadius(ctx context.Context, phoneId string) float64 {
+	chatHistory, err := s.gemini.GetChatHistory(ctx, phoneId)
+	if err != nil {
+		return 0
+	}
+
+	for i := len(chatHistory) - 1; i >= 0; i-- {
+		call := chatHistory[i].Message.ToolCall
+		if call == nil || call.Name != dto.FindNearbyEvents.String() {
+			continue
+		}
+
+		if args, fnErr := s.context.decodeArgs(call); fnErr == nil {
+			return args.(*dto.FindNearbyEventsArgs).RadiusKm
+		}
+
+		return 0
+	}
+
+	return 0
+}
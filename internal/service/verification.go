@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+	"github.com/xerdin442/ticketing-bot/internal/render"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+)
+
+const unverifiedReply = "Sorry, I couldn't confirm those details. Please ask again and I'll check the latest event information for you."
+
+// verifyModelText checks the event titles, dates, IDs, tier names and prices in the model's text against
+// the function call executed in the turn and its response. If any of them cannot be found, the model is asked
+// to correct its reply once, after which the data is rendered with a template instead. The returned
+// flag is false if the reply was replaced by the template.
+func (s *GeminiService) verifyModelText(ctx context.Context, phoneId, text string, call *llm.ToolCall, response map[string]any) (string, bool) {
+	var args map[string]any
+	if call != nil {
+		args = call.Args
+	}
+
+	violations := s.guard.CheckOutput(text, response, args)
+	if len(violations) == 0 {
+		return text, true
+	}
+
+	log.Warn().Strs("violations", violations).Msg("Model response contains details missing from backend data")
+
+	// Regenerate the response with a note on the details that could not be verified
+	chatHistory, err := s.GetChatHistory(ctx, phoneId)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error fetching chat history for response verification")
+	} else if corrected, ok := s.regenerateResponse(ctx, phoneId, chatHistory, violations); ok {
+		if remaining := s.guard.CheckOutput(corrected, response, args); len(remaining) == 0 {
+			s.replaceLastModelText(ctx, phoneId, text, corrected)
+			return corrected, true
+		}
+	}
+
+	// Fall back to a deterministic rendering of the backend data
+	fallback := render.FunctionResponse(response)
+	if fallback == "" {
+		fallback = unverifiedReply
+	}
+
+	log.Warn().Msg("Model response replaced with template rendering of backend data")
+	s.replaceLastModelText(ctx, phoneId, text, fallback)
+
+	return fallback, false
+}
+
+func (s *GeminiService) regenerateResponse(ctx context.Context, phoneId string, chatHistory []dto.ConversationContext, violations []string) (string, bool) {
+	note := fmt.Sprintf(
+		"System correction: Your last reply mentioned details that are not in the data returned by the backend service: %s. "+
+			"Rewrite the reply using only event titles, dates, IDs, ticket tiers and prices from the function responses. "+
+			"Do not mention these corrections to the user.",
+		strings.Join(violations, ", "),
+	)
+
+	messages := s.BuildModelHistory(ctx, phoneId, chatHistory)
+	messages = append(messages, &llm.Message{Role: llm.RoleUser, Text: note})
+
+	resp, err := s.GenerateModelResponse(ctx, phoneId, messages)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error regenerating model response")
+		return "", false
+	}
+
+	// A function call at this point cannot be executed without restarting the turn
+	if resp.Message.ToolCall != nil || resp.Message.Text == "" {
+		return "", false
+	}
+
+	return resp.Message.Text, true
+}
+
+// replaceLastModelText swaps the unverified reply stored in the chat history for the reply sent to the user
+func (s *GeminiService) replaceLastModelText(ctx context.Context, phoneId, previous, text string) {
+	chatHistory, err := s.GetChatHistory(ctx, phoneId)
+	if err != nil || len(chatHistory) == 0 {
+		return
+	}
+
+	last := chatHistory[len(chatHistory)-1]
+	if last.Message.Role != llm.RoleModel || last.Message.Text != previous {
+		return
+	}
+
+	last.Message = &llm.Message{Role: llm.RoleModel, Text: text}
+
+	cacheKey := "chat_history:" + util.CreateHashedKey(phoneId)
+	if err := s.cache.LSet(ctx, cacheKey, -1, &last).Err(); err != nil {
+		log.Warn().Err(err).Msg("Error replacing unverified model response in chat history")
+	}
+}
@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/backend"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+)
+
+const stepCheckout = "checkout"
+
+func NewCheckoutRenewalTask(data dto.PaymentWebhookPayload) (*asynq.Task, error) {
+	payload, _ := json.Marshal(data)
+
+	return asynq.NewTask("checkout_renewal_queue", payload,
+		asynq.TaskID("checkout_renewal:"+data.Reference),
+		asynq.Retention(paymentNotificationTTL),
+		asynq.MaxRetry(paymentMaxRetry),
+	), nil
+}
+
+// HandleCheckoutRenewalTask sends a fresh checkout link to a user whose checkout link expired
+func (h *TaskHandler) HandleCheckoutRenewalTask(ctx context.Context, t *asynq.Task) error {
+	var p dto.PaymentWebhookPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Error().Err(err).Msg("Error parsing checkout renewal payload: Invalid structure")
+		return fmt.Errorf("Error parsing checkout renewal payload: %s: %w", err.Error(), asynq.SkipRetry)
+	}
+
+	progress := newCheckpoint(h.cache, "checkout_renewal:"+p.Reference)
+
+	// The new link is kept, so a retry does not create another checkout
+	checkout, renewed, err := progress.get(ctx, stepCheckout)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if renewed {
+		text, _, err = progress.get(ctx, stepResponse)
+		if err != nil {
+			return err
+		}
+	} else {
+		checkout, text, err = h.renewCheckout(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		// The link and its message are recorded together, so a retry never finds one without the other
+		if err := progress.markAll(ctx, map[string]string{stepCheckout: checkout, stepResponse: text}); err != nil {
+			return err
+		}
+	}
+
+	if done, err := progress.done(ctx, stepReplySent); err != nil {
+		return err
+	} else if !done {
+		if err := h.sendTextMessage(ctx, p.PhoneID, text); err != nil {
+			log.Error().Err(err).Msg("Error sending renewed checkout link to user")
+			return err
+		}
+
+		if err := progress.mark(ctx, stepReplySent, "1"); err != nil {
+			return err
+		}
+	}
+
+	// Without a new link, the user has to select the tickets again
+	state := dto.StateAwaitingPayment
+	if checkout == "" {
+		state = dto.StateInitial
+	}
+
+	if done, err := progress.done(ctx, stepReplyRecorded); err != nil {
+		return err
+	} else if !done {
+		modelContext := &dto.ConversationContext{
+			Message:      &llm.Message{Role: llm.RoleModel, Text: text},
+			CurrentState: state,
+		}
+		if err := h.gemini.UpdateChatHistory(ctx, p.PhoneID, modelContext); err != nil {
+			return err
+		}
+
+		if err := progress.mark(ctx, stepReplyRecorded, "1"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renewCheckout creates a new checkout link for the ticket selection of the user. The link is
+// empty if the selection has expired or the tickets can no longer be bought.
+func (h *TaskHandler) renewCheckout(ctx context.Context, p dto.PaymentWebhookPayload) (string, string, error) {
+	result, err := h.context.InitiateTicketPurchase(ctx, p.Email, p.PhoneID)
+	if errors.Is(err, backend.ErrNotFound) || errors.Is(err, backend.ErrValidation) {
+		log.Warn().Err(err).Str("reference", p.Reference).Msg("Backend service rejected checkout renewal")
+		text := "I couldn't create a new checkout link, as the tickets you selected may no longer be available. Tell me which event you're interested in, and I'll help you start again."
+		return "", text, nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	checkout, _ := result["checkout"].(string)
+	if checkout == "" {
+		text := "Your ticket selection has also expired, so I couldn't create a new checkout link. Tell me which event you're interested in, and I'll help you start again."
+		return "", text, nil
+	}
+
+	text := "Here's a fresh checkout link for your tickets:\n" + checkout
+	if total, ok := result["total"].(dto.Money); ok {
+		text += fmt.Sprintf("\n\nTotal: *%s*", total)
+	}
+
+	return checkout, text + "\n\nPlease complete your payment soon, as the link is only valid for a limited time.", nil
+}
@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// How long the completed steps of a task are remembered
+const checkpointTTL = 24 * time.Hour
+
+// checkpoint records the steps of a task that succeeded, so they are skipped when the task is retried
+type checkpoint struct {
+	cache *redis.Client
+	key   string
+}
+
+func newCheckpoint(cache *redis.Client, key string) *checkpoint {
+	return &checkpoint{cache: cache, key: "task_checkpoint:" + key}
+}
+
+// get returns the value recorded for a completed step, or false if the step has not completed
+func (c *checkpoint) get(ctx context.Context, step string) (string, bool, error) {
+	value, err := c.cache.HGet(ctx, c.key, step).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("Error fetching task checkpoint from cache: %s", err.Error())
+	}
+
+	return value, true, nil
+}
+
+func (c *checkpoint) done(ctx context.Context, step string) (bool, error) {
+	_, ok, err := c.get(ctx, step)
+	return ok, err
+}
+
+// mark records a step as completed, along with any value later steps depend on
+func (c *checkpoint) mark(ctx context.Context, step, value string) error {
+	return c.markAll(ctx, map[string]string{step: value})
+}
+
+// markAll records several steps as completed in a single write, so either all or none of them are recorded
+func (c *checkpoint) markAll(ctx context.Context, steps map[string]string) error {
+	_, err := c.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, c.key, steps)
+		pipe.Expire(ctx, c.key, checkpointTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error storing task checkpoint in cache: %s", err.Error())
+	}
+
+	return nil
+}
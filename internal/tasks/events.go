@@ -0,0 +1,281 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/xerdin442/ticketing-bot/internal/api/dto"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+	"github.com/xerdin442/ticketing-bot/internal/util"
+)
+
+const (
+	// Retries of an event update task. Users already notified are skipped on each retry.
+	eventUpdateMaxRetry = 3
+	// How long the users notified by an event update task are remembered
+	eventNotifiedTTL = 24 * time.Hour
+)
+
+// NewEventUpdateTask creates the task for an event notification. The task ID is derived from the notification
+// and the time it was signed, so the queue rejects a notification that is delivered again.
+func NewEventUpdateTask(data dto.EventWebhookPayload, sentAt string) (*asynq.Task, error) {
+	payload, _ := json.Marshal(data)
+
+	return asynq.NewTask("event_update_queue", payload,
+		asynq.TaskID(fmt.Sprintf("event_update:%d:%s:%s", data.EventID, data.Type, sentAt)),
+		asynq.Retention(eventNotifiedTTL),
+		asynq.MaxRetry(eventUpdateMaxRetry),
+	), nil
+}
+
+func (h *TaskHandler) HandleEventUpdateTask(ctx context.Context, t *asynq.Task) error {
+	var p dto.EventWebhookPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		log.Error().Err(err).Msg("Error parsing event update payload: Invalid structure")
+		return fmt.Errorf("Error parsing event update payload: %s: %w", err.Error(), asynq.SkipRetry)
+	}
+
+	// Users notified in an earlier attempt of the task are skipped when it is retried. The task ID
+	// stays the same across retries, and is the same for every delivery of the notification.
+	taskId, ok := asynq.GetTaskID(ctx)
+	if !ok || taskId == "" {
+		return fmt.Errorf("Error processing event update: Missing task ID: %w", asynq.SkipRetry)
+	}
+	notifiedKey := "event_notified:" + taskId
+
+	conversations, err := h.eventConversations(ctx, p.EventID)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	notify := func(phoneId, text string, holder bool, state dto.ConversationState) bool {
+		// The user is claimed before the message is sent, so a retry never sends the message twice
+		var added *redis.IntCmd
+		_, err := h.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			added = pipe.SAdd(ctx, notifiedKey, phoneId)
+			pipe.Expire(ctx, notifiedKey, eventNotifiedTTL)
+			return nil
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Error recording notified user in cache")
+			failed++
+			return false
+		} else if added.Val() == 0 {
+			return true
+		}
+
+		if err := h.sendEventNotice(ctx, phoneId, text, templateNote(p, holder), p.Title); err != nil {
+			log.Error().Err(err).Int("event_id", p.EventID).Msg("Error notifying user of event update")
+			if err := h.cache.SRem(ctx, notifiedKey, phoneId).Err(); err != nil {
+				log.Error().Err(err).Msg("Error removing user from notified users in cache")
+			}
+			failed++
+			return false
+		}
+
+		// Add notification to conversation history, so the model knows about the change
+		h.gemini.UpdateChatHistory(ctx, phoneId, &dto.ConversationContext{
+			Message:      &llm.Message{Role: llm.RoleModel, Text: text},
+			CurrentState: state,
+		})
+
+		return true
+	}
+
+	// Ticket holders are told first, since they may also have a conversation on the event
+	holders := make(map[string]bool, len(p.TicketHolders))
+	if text := ticketHolderNotice(p); text != "" {
+		for _, phoneId := range p.TicketHolders {
+			holders[phoneId] = true
+			notify(phoneId, text, true, dto.StateCompleted)
+		}
+	}
+
+	for _, phoneId := range conversations {
+		if holders[phoneId] {
+			continue
+		}
+
+		if p.Type == "updated" {
+			notify(phoneId, conversationNotice(p), false, dto.StateEventSelected)
+			continue
+		}
+
+		// The purchase can no longer go ahead once the event is cancelled or sold out. The selection
+		// is only cleared once the user is notified, so the user is found again if the task is retried.
+		if notify(phoneId, conversationNotice(p), false, dto.StateEventQuery) {
+			hashedKey := util.CreateHashedKey(phoneId)
+			if err := h.cache.Del(ctx, "selected_event:"+hashedKey, "ticket_purchase:"+hashedKey).Err(); err != nil {
+				log.Error().Err(err).Msg("Error clearing selected event from cache")
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Error notifying %d users of update to event with ID: %d", failed, p.EventID)
+	}
+
+	log.Info().Int("event_id", p.EventID).Str("type", p.Type).Msg("Notified users of event update")
+	return nil
+}
+
+// eventConversations returns the users whose conversation is still on the event
+func (h *TaskHandler) eventConversations(ctx context.Context, eventId int) ([]string, error) {
+	members, err := h.cache.SMembers(ctx, fmt.Sprintf("event_conversations:%d", eventId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching conversations on event from cache: %s", err.Error())
+	}
+
+	var phoneIds []string
+	for _, phoneId := range members {
+		// Skip users who have since selected another event
+		selected, err := h.cache.Get(ctx, "selected_event:"+util.CreateHashedKey(phoneId)).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("Error fetching selected event from cache: %s", err.Error())
+		}
+
+		if selected == strconv.Itoa(eventId) {
+			phoneIds = append(phoneIds, phoneId)
+		}
+	}
+
+	return phoneIds, nil
+}
+
+func conversationNotice(p dto.EventWebhookPayload) string {
+	switch p.Type {
+	case "cancelled":
+		return fmt.Sprintf("Heads up: *%s* has been cancelled by the organizer, so tickets can no longer be bought for it. Any checkout link you received for it will not work. Would you like to find another event?", p.Title)
+	case "sold_out":
+		return fmt.Sprintf("Heads up: *%s* is now sold out, so any checkout link you received for it may no longer work. Would you like to find another event?", p.Title)
+	default:
+		return fmt.Sprintf("Heads up: the organizer of *%s* has updated the event.%sReply if you would like to see the latest details and ticket prices.", p.Title, changesNote(p))
+	}
+}
+
+// ticketHolderNotice returns the message for users holding tickets, or an empty string if they need not be told
+func ticketHolderNotice(p dto.EventWebhookPayload) string {
+	switch p.Type {
+	case "cancelled":
+		return fmt.Sprintf("We're sorry, *%s*, which you hold tickets for, has been cancelled by the organizer. Details of your refund will be sent to your email address.", p.Title)
+	case "updated":
+		return fmt.Sprintf("The organizer of *%s*, which you hold tickets for, has updated the event.%sYour tickets remain valid.", p.Title, changesNote(p))
+	default:
+		return ""
+	}
+}
+
+func changesNote(p dto.EventWebhookPayload) string {
+	if p.Changes == nil || *p.Changes == "" {
+		return " "
+	}
+
+	return "\n\n" + *p.Changes + "\n\n"
+}
+
+// sendEventNotice sends an event notice as a text message to users who messaged within the last 24 hours,
+// and as the approved event template to everyone else, e.g. ticket holders who bought their tickets long ago
+func (h *TaskHandler) sendEventNotice(ctx context.Context, phoneId, text, note, title string) error {
+	open, err := h.cache.Exists(ctx, "service_window:"+util.CreateHashedKey(phoneId)).Result()
+	if err != nil {
+		return fmt.Errorf("Error fetching customer service window from cache: %s", err.Error())
+	}
+
+	if open > 0 {
+		return h.sendTextMessage(ctx, phoneId, text)
+	}
+
+	// The template reads "Update on {{1}}: {{2}}"
+	template := &dto.ReplyTemplate{Name: h.env.WhatsappEventTemplate}
+	template.Language.Code = h.env.WhatsappTemplateLanguage
+	template.Components = []dto.ReplyTemplateComponent{{
+		Type: "body",
+		Parameters: []dto.ReplyTemplateParameter{
+			{Type: "text", Text: title},
+			{Type: "text", Text: note},
+		},
+	}}
+
+	return h.sendMessage(ctx, dto.MessageRequestPayload{
+		MessagingProduct: "whatsapp",
+		RecipientType:    ptr("individual"),
+		To:               &phoneId,
+		Type:             ptr("template"),
+		Template:         template,
+	})
+}
+
+// templateNote describes an event update in the event template, which cannot hold line breaks
+func templateNote(p dto.EventWebhookPayload, holder bool) string {
+	var note string
+	switch p.Type {
+	case "cancelled":
+		note = "The event has been cancelled by the organizer."
+		if holder {
+			note += " Details of your refund will be sent to your email address."
+		}
+	case "sold_out":
+		note = "The event is now sold out."
+	default:
+		note = "The organizer has updated the event."
+		if p.Changes != nil && strings.TrimSpace(*p.Changes) != "" {
+			note += " " + strings.Join(strings.Fields(*p.Changes), " ")
+		}
+		if holder {
+			note += " Your tickets remain valid."
+		}
+	}
+
+	return note
+}
+
+func (h *TaskHandler) sendTextMessage(ctx context.Context, phoneId, text string) error {
+	return h.sendMessage(ctx, dto.MessageRequestPayload{
+		MessagingProduct: "whatsapp",
+		RecipientType:    ptr("individual"),
+		To:               &phoneId,
+		Type:             ptr("text"),
+		Text: &dto.ReplyText{
+			PreviewURL: true,
+			Body:       text,
+		},
+	})
+}
+
+func (h *TaskHandler) sendMessage(ctx context.Context, payload dto.MessageRequestPayload) error {
+	// Configure request details
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", h.env.WhatsappMessagingApiUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("Error configuring new HTTP request: %s", err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.env.WhatsappUserAccessToken)
+
+	httpClient := http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending request to Whatsapp Cloud API: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error sending message to user. Status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package tasks
 
 import (
+	"context"
+
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
 	"github.com/xerdin442/ticketing-bot/internal/secrets"
@@ -9,18 +11,25 @@ import (
 
 type TasksClient interface {
 	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
 }
 
 type TaskHandler struct {
-	env    *secrets.Secrets
-	cache  *redis.Client
-	gemini *service.GeminiService
+	env     *secrets.Secrets
+	cache   *redis.Client
+	queue   TasksClient
+	context *service.ContextService
+	gemini  *service.GeminiService
 }
 
-func NewHandler(s *secrets.Secrets, r *redis.Client) *TaskHandler {
+func NewHandler(s *secrets.Secrets, r *redis.Client, q TasksClient) *TaskHandler {
+	contextService := service.NewContextService(s, r)
+
 	return &TaskHandler{
-		env:    s,
-		cache:  r,
-		gemini: service.NewGeminiService(s, r),
+		env:     s,
+		cache:   r,
+		queue:   q,
+		context: contextService,
+		gemini:  service.NewGeminiService(s, r, contextService),
 	}
 }
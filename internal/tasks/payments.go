@@ -1,131 +1,270 @@
 package tasks
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 	"github.com/xerdin442/ticketing-bot/internal/api/dto"
-	"google.golang.org/genai"
+	"github.com/xerdin442/ticketing-bot/internal/llm"
+)
+
+const (
+	paymentInProgress = "in_progress"
+	paymentProcessed  = "processed"
+	// How long a payment notification is remembered after it is claimed
+	paymentNotificationTTL = 24 * time.Hour
+	// Retries of a payment task before it is archived, spread over about ten hours by RetryDelay
+	paymentMaxRetry = 12
+)
+
+// Steps of a payment task that are skipped once they succeed
+const (
+	stepStatusRecorded = "status_recorded"
+	stepResponse       = "response"
+	stepReplySent      = "reply_sent"
+	stepReplyRecorded  = "reply_recorded"
+	stepFollowUp       = "follow_up"
 )
 
 func ptr(s string) *string {
 	return &s
 }
 
+// paymentNotificationId identifies a payment notification. A reference can receive one
+// notification per status, e.g. a refund after a successful payment.
+func paymentNotificationId(p dto.PaymentWebhookPayload) string {
+	return p.Reference + ":" + string(p.Status)
+}
+
+// ClaimPaymentNotification atomically marks a payment notification as in progress. It returns
+// false if the notification has already been claimed, whether or not it has been processed.
+func ClaimPaymentNotification(ctx context.Context, cache *redis.Client, p dto.PaymentWebhookPayload) (bool, error) {
+	cacheKey := "payment_notification:" + paymentNotificationId(p)
+
+	claimed, err := cache.SetNX(ctx, cacheKey, paymentInProgress, paymentNotificationTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("Error claiming payment notification in cache: %s", err.Error())
+	}
+
+	return claimed, nil
+}
+
+// ReleasePaymentNotification removes the claim of a payment notification that could not be queued,
+// so the notification is accepted when the backend service sends it again
+func ReleasePaymentNotification(ctx context.Context, cache *redis.Client, p dto.PaymentWebhookPayload) error {
+	if err := cache.Del(ctx, "payment_notification:"+paymentNotificationId(p)).Err(); err != nil {
+		return fmt.Errorf("Error releasing payment notification in cache: %s", err.Error())
+	}
+
+	return nil
+}
+
 func NewPaymentWebhookTask(data dto.PaymentWebhookPayload) (*asynq.Task, error) {
 	payload, _ := json.Marshal(data)
-	return asynq.NewTask("payment_queue", payload), nil
+
+	// The queue rejects a second task for the same notification, including after the first one completes
+	return asynq.NewTask("payment_queue", payload,
+		asynq.TaskID("payment:"+paymentNotificationId(data)),
+		asynq.Retention(paymentNotificationTTL),
+		asynq.MaxRetry(paymentMaxRetry),
+	), nil
+}
+
+// paymentFallbackMessage returns the message sent for a payment status when the model cannot generate one
+func paymentFallbackMessage(p dto.PaymentWebhookPayload) string {
+	switch p.Status {
+	case dto.PaymentSuccess:
+		return fmt.Sprintf("Your payment was successful! Your tickets have been sent to %s.", p.Email)
+	case dto.PaymentFailed:
+		return "Unfortunately, your payment was not successful." + reasonNote(p) + " If you were debited, the amount will be reversed. Reply if you would like to try again."
+	case dto.PaymentExpired:
+		return "Your checkout link has expired before the payment was completed. I'm creating a fresh link for you now."
+	case dto.PaymentAbandoned:
+		return "It looks like you left the checkout before completing your payment. Your ticket selection is still saved. Reply if you would like a new checkout link."
+	case dto.PaymentRefund:
+		return fmt.Sprintf("Your refund has been processed.%s Details have been sent to %s, and the amount should reach your account within a few working days.", reasonNote(p), p.Email)
+	case dto.PaymentPartialRefund:
+		amount := "Part of your payment"
+		if p.RefundedAmount != nil {
+			amount = p.RefundedAmount.String()
+		}
+
+		return fmt.Sprintf("%s has been refunded.%s Details have been sent to %s, and the amount should reach your account within a few working days.", amount, reasonNote(p), p.Email)
+	case dto.PaymentChargeback:
+		return fmt.Sprintf("Your payment was disputed with your bank and has been reversed, so the tickets for this order are no longer valid. If this was a mistake, please contact support and quote the reference %s.", p.Reference)
+	default:
+		return "Your payment is being processed. We'll message you as soon as it is confirmed."
+	}
+}
+
+func reasonNote(p dto.PaymentWebhookPayload) string {
+	if p.Reason == nil || *p.Reason == "" {
+		return ""
+	}
+
+	return " Reason: " + strings.TrimSuffix(*p.Reason, ".") + "."
 }
 
 func (h *TaskHandler) HandlePaymentWebhookTask(ctx context.Context, t *asynq.Task) error {
 	var p dto.PaymentWebhookPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
 		log.Error().Err(err).Msg("Error parsing payment webhook payload: Invalid structure")
-		return err
+		return fmt.Errorf("Error parsing payment webhook payload: %s: %w", err.Error(), asynq.SkipRetry)
 	}
 
+	progress := newCheckpoint(h.cache, "payment:"+paymentNotificationId(p))
+
 	// Update conversation history with payment status
-	apiContext := map[string]any{
-		"status": p.Status,
-		"email":  p.Email,
-		"reason": p.Reason,
-	}
+	if done, err := progress.done(ctx, stepStatusRecorded); err != nil {
+		return err
+	} else if !done {
+		apiContext := map[string]any{
+			"status": p.Status,
+			"email":  p.Email,
+			"reason": p.Reason,
+		}
+
+		if p.RefundedAmount != nil {
+			apiContext["refundedAmount"] = p.RefundedAmount
+		}
 
-	// Add function result to conversation history
-	functionResult := &dto.ConversationContext{
-		Content: &genai.Content{
-			Role: "system",
-			Parts: []*genai.Part{{
-				FunctionResponse: &genai.FunctionResponse{
-					Name:     dto.InitiateTicketPurchase.String(),
-					Response: apiContext,
-				},
-			}},
-		},
-		CurrentState: dto.StateCompleted,
+		if p.Status == dto.PaymentExpired {
+			apiContext["followUp"] = "A new checkout link is being created and will be sent to the user in a separate message"
+		}
+
+		// Add payment status to conversation history. It is recorded as a notice rather than a function
+		// result, since no function call of the model is waiting for it
+		status, _ := json.Marshal(apiContext)
+		paymentNotice := &dto.ConversationContext{
+			Message: &llm.Message{
+				Role: llm.RoleUser,
+				Text: "System notice: The payment for the ticket purchase was updated: " + string(status),
+			},
+			CurrentState: p.Status.ConversationState(),
+		}
+		if err := h.gemini.UpdateChatHistory(ctx, p.PhoneID, paymentNotice); err != nil {
+			return err
+		}
+
+		if err := progress.mark(ctx, stepStatusRecorded, "1"); err != nil {
+			return err
+		}
 	}
-	h.gemini.UpdateChatHistory(p.PhoneID, functionResult)
 
-	// Fetch current conversation history
-	chatHistory, err := h.gemini.GetChatHistory(p.PhoneID)
+	// The generated response is kept, so a retry sends the same message instead of generating a new one
+	modelResponse, generated, err := progress.get(ctx, stepResponse)
 	if err != nil {
 		return err
 	}
 
-	var contents []*genai.Content
-	for _, context := range chatHistory {
-		contents = append(contents, context.Content)
-	}
+	if !generated {
+		// Fetch current conversation history
+		chatHistory, err := h.gemini.GetChatHistory(ctx, p.PhoneID)
+		if err != nil {
+			return err
+		}
 
-	// Generate response from model
-	var modelResponse string
-	response, err := h.gemini.GenerateModelResponse(contents)
-	if err != nil {
-		modelResponse = "Your payment is being processed."
-	}
-	modelResponse = response.Candidates[0].Content.Parts[0].Text
-
-	// Configure request payload
-	payload := dto.MessageRequestPayload{
-		MessagingProduct: "whatsapp",
-		RecipientType:    ptr("individual"),
-		To:               &p.PhoneID,
-		Type:             ptr("text"),
-		Text: &dto.ReplyText{
-			PreviewURL: true,
-			Body:       modelResponse,
-		},
+		messages := h.gemini.BuildModelHistory(ctx, p.PhoneID, chatHistory)
+
+		// Generate response from model, falling back to a fixed message for the payment status
+		response, err := h.gemini.GenerateModelResponse(ctx, p.PhoneID, messages)
+		switch {
+		case err != nil:
+			log.Warn().Err(err).Str("reference", p.Reference).Msg("Error generating payment response. Sending fallback message")
+			modelResponse = paymentFallbackMessage(p)
+		case response == nil || response.Message == nil || strings.TrimSpace(response.Message.Text) == "":
+			log.Warn().Str("reference", p.Reference).Msg("Model returned no text for payment response. Sending fallback message")
+			modelResponse = paymentFallbackMessage(p)
+		default:
+			modelResponse = response.Message.Text
+		}
+
+		if err := progress.mark(ctx, stepResponse, modelResponse); err != nil {
+			return err
+		}
 	}
 
-	// Configure request details
-	body, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", h.env.WhatsappMessagingApiUrl, bytes.NewBuffer(body))
-	if err != nil {
-		log.Error().Err(err).Msg("Error configuring new HTTP request")
+	if done, err := progress.done(ctx, stepReplySent); err != nil {
 		return err
-	}
+	} else if !done {
+		if err := h.sendTextMessage(ctx, p.PhoneID, modelResponse); err != nil {
+			log.Error().Err(err).Msg("Error sending payment confirmation to user")
+			return err
+		}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+h.env.WhatsappUserAccessToken)
+		if err := progress.mark(ctx, stepReplySent, "1"); err != nil {
+			return err
+		}
+	}
 
-	// Send request to backend service
-	httpClient := http.Client{Timeout: 30 * time.Second}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		log.Error().Err(err).Msg("Error sending request to Whatsapp Cloud API")
+	// Add model response to conversation history
+	if done, err := progress.done(ctx, stepReplyRecorded); err != nil {
 		return err
+	} else if !done {
+		modelContext := &dto.ConversationContext{
+			Message: &llm.Message{
+				Role: llm.RoleModel,
+				Text: modelResponse,
+			},
+			CurrentState: p.Status.ConversationState(),
+		}
+		if err := h.gemini.UpdateChatHistory(ctx, p.PhoneID, modelContext); err != nil {
+			return err
+		}
+
+		if err := progress.mark(ctx, stepReplyRecorded, "1"); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Error().Int("status_code", resp.StatusCode).Msg("Error sending payment confirmation to user")
+	if done, err := progress.done(ctx, stepFollowUp); err != nil {
 		return err
+	} else if !done {
+		if err := h.paymentFollowUp(ctx, p); err != nil {
+			return err
+		}
+
+		if err := progress.mark(ctx, stepFollowUp, "1"); err != nil {
+			return err
+		}
 	}
 
-	// Add model response to conversation history
-	modelContext := &dto.ConversationContext{
-		Content: &genai.Content{
-			Role: genai.RoleModel,
-			Parts: []*genai.Part{{
-				Text: modelResponse,
-			}},
-		},
-		CurrentState: dto.StateCompleted,
+	// Mark the notification as processed to reject duplicates
+	cacheKey := "payment_notification:" + paymentNotificationId(p)
+	if err := h.cache.Set(ctx, cacheKey, paymentProcessed, paymentNotificationTTL).Err(); err != nil {
+		log.Error().Err(err).Msg("Error storing payment webhook reference in cache")
+		return err
 	}
-	h.gemini.UpdateChatHistory(p.PhoneID, modelContext)
-
-	// Store notification ID in Redis to prevent duplicate processing
-	cacheKey := fmt.Sprintf("payment_notification:%s", p.Reference)
-	_, cacheErr := h.cache.Set(context.Background(), cacheKey, "processed", 24*time.Hour).Result()
-	if cacheErr != nil {
-		log.Error().Err(cacheErr).Msg("Error storing payment webhook reference in cache")
-		return cacheErr
+
+	return nil
+}
+
+// paymentFollowUp runs the work that follows the message for a payment status
+func (h *TaskHandler) paymentFollowUp(ctx context.Context, p dto.PaymentWebhookPayload) error {
+	switch p.Status {
+	case dto.PaymentExpired:
+		// Send a fresh checkout link in a separate task, so a failure to create it does not resend the status message
+		task, err := NewCheckoutRenewalTask(p)
+		if err != nil {
+			return err
+		}
+
+		_, err = h.queue.EnqueueContext(ctx, task)
+		if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return fmt.Errorf("Error adding checkout renewal to queue: %s", err.Error())
+		}
+	case dto.PaymentChargeback:
+		log.Error().
+			Bool("alert", true).
+			Str("reference", p.Reference).
+			Msg("Chargeback received for ticket purchase")
 	}
 
 	return nil
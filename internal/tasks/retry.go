@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = 4 * time.Hour
+)
+
+// RetryDelay returns the exponential delay with jitter before a failed task is retried. The delay
+// starts at 10 seconds, and doubles with each retry up to 4 hours.
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	delay := retryMaxDelay
+	if n < 12 {
+		delay = min(retryBaseDelay<<n, retryMaxDelay)
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(delay / 4)))
+	return delay + jitter
+}
+
+// HandleTaskError logs every failed attempt of a task, and raises an alert when the task is archived
+// after its final attempt or a failure that cannot be retried
+func HandleTaskError(ctx context.Context, t *asynq.Task, err error) {
+	taskId, _ := asynq.GetTaskID(ctx)
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	if retried >= maxRetry || errors.Is(err, asynq.SkipRetry) {
+		log.Error().
+			Err(err).
+			Bool("alert", true).
+			Str("task_type", t.Type()).
+			Str("task_id", taskId).
+			Int("retried", retried).
+			Msg("Task failed permanently and was archived")
+		return
+	}
+
+	log.Warn().
+		Err(err).
+		Str("task_type", t.Type()).
+		Str("task_id", taskId).
+		Int("retried", retried).
+		Int("max_retry", maxRetry).
+		Msg("Task failed. Retrying")
+}
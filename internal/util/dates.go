@@ -0,0 +1,86 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const isoDateLayout = "2006-01-02"
+
+// Date formats the model sometimes returns instead of the requested ISO format
+var dateLayouts = []string{
+	isoDateLayout,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006/01/02",
+	"02/01/2006",
+	"02-01-2006",
+	"2 January 2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+}
+
+// NormalizeISODate converts a date string to the YYYY-MM-DD format
+func NormalizeISODate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			year, month, day := t.In(loc).Date()
+			return time.Date(year, month, day, 0, 0, 0, 0, loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid date value: %q. Expected format: YYYY-MM-DD", value)
+}
+
+// ValidateDateRange normalizes the start and end dates of an event search. A range that started in
+// the past is moved to start today, while dates that are entirely in the past are rejected.
+func ValidateDateRange(startDate, endDate string, now time.Time) (string, string, error) {
+	loc := now.Location()
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	var start, end time.Time
+	var err error
+
+	if startDate != "" {
+		if start, err = NormalizeISODate(startDate, loc); err != nil {
+			return "", "", err
+		}
+	}
+
+	if endDate != "" {
+		if end, err = NormalizeISODate(endDate, loc); err != nil {
+			return "", "", err
+		}
+
+		if end.Before(today) {
+			return "", "", fmt.Errorf("End date %s is in the past. Today is %s", end.Format(isoDateLayout), today.Format(isoDateLayout))
+		}
+
+		if !start.IsZero() && end.Before(start) {
+			return "", "", fmt.Errorf("End date %s is before start date %s", end.Format(isoDateLayout), start.Format(isoDateLayout))
+		}
+	}
+
+	if !start.IsZero() && start.Before(today) {
+		if end.IsZero() {
+			return "", "", fmt.Errorf("Start date %s is in the past. Today is %s", start.Format(isoDateLayout), today.Format(isoDateLayout))
+		}
+
+		start = today
+	}
+
+	var normalizedStart, normalizedEnd string
+	if !start.IsZero() {
+		normalizedStart = start.Format(isoDateLayout)
+	}
+	if !end.IsZero() {
+		normalizedEnd = end.Format(isoDateLayout)
+	}
+
+	return normalizedStart, normalizedEnd, nil
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"strings"
+	"time"
+
+	// Embed the timezone database so locations resolve on hosts without one
+	_ "time/tzdata"
+)
+
+const DefaultTimezone = "Africa/Lagos"
+
+// Timezones of the countries users are most likely to message from, keyed by calling code
+var callingCodeTimezones = []struct {
+	prefix   string
+	timezone string
+}{
+	{"234", "Africa/Lagos"},
+	{"233", "Africa/Accra"},
+	{"254", "Africa/Nairobi"},
+	{"27", "Africa/Johannesburg"},
+	{"44", "Europe/London"},
+	{"971", "Asia/Dubai"},
+	{"1", "America/New_York"},
+}
+
+// UserLocation returns the timezone of a user, inferred from the calling code of their phone number
+func UserLocation(phoneId string) *time.Location {
+	name := DefaultTimezone
+	for _, c := range callingCodeTimezones {
+		if strings.HasPrefix(strings.TrimPrefix(phoneId, "+"), c.prefix) {
+			name = c.timezone
+			break
+		}
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}